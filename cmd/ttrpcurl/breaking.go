@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newBreakingCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "breaking --old <protos> --new <protos>",
+		Example: "ttrpcurl breaking --old=v1/api.proto --new=api.proto",
+		Short:   "Check two versions of proto files for breaking changes",
+		Long: prettify(`
+			Compare the old and new version of proto files and report changes that
+			break clients or servers using the old version, like removed services,
+			methods, messages, fields and enum values, changed field numbers and
+			types and changed streaming kinds. Changes that keep the wire format
+			compatible, like renamed fields, are reported as compatible.
+			The command fails if there are breaking changes. The versions are
+			given by --old and --new instead of --proto, their imports are
+			resolved from --old-import-path and --new-import-path instead of
+			--import-path, so the versions don't share import paths.`),
+		Args: cobra.NoArgs,
+		RunE: runBreaking,
+	}
+
+	cmd.Flags().StringSlice("old", nil, prettify(`
+		The old version of the proto files. Accepts proto files, directories,
+		archives, module references or protoset files with the extension
+		'.protoset' or '.pb'. Of protoset files, only the files that aren't
+		imported by another file of the set are compared. May specify more
+		than one via repeated use of the flag or comma-separated values.`))
+	cmd.Flags().StringSlice("new", nil, prettify(`
+		The new version of the proto files. Accepts the same values as --old.`))
+	cmd.Flags().StringSlice("old-import-path", nil, prettify(`
+		The path to a directory, archive or module from which imports of the
+		old version are resolved. May specify more than one via repeated use
+		of the flag or comma-separated values.`))
+	cmd.Flags().StringSlice("new-import-path", nil, prettify(`
+		The path to a directory, archive or module from which imports of the
+		new version are resolved. Accepts the same values as --old-import-path.`))
+	addOutputFlag(cmd)
+
+	return cmd
+}
+
+func runBreaking(cmd *cobra.Command, _ []string) error {
+	flags, err := parseBreakingFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	oldSrc, err := loadVersion(flags.old, flags.oldImportPaths, flags.noCache)
+	if err != nil {
+		return fmt.Errorf("loading old version: %w", err)
+	}
+	newSrc, err := loadVersion(flags.new, flags.newImportPaths, flags.noCache)
+	if err != nil {
+		return fmt.Errorf("loading new version: %w", err)
+	}
+	changes := proto.FindChanges(oldSrc, newSrc)
+
+	if flags.output != "text" {
+		if err := printStructured(os.Stdout, flags.output, changes); err != nil {
+			return err
+		}
+	} else {
+		for _, change := range changes.Changes {
+			level := "Compatible"
+			if change.Breaking {
+				level = "Breaking"
+			}
+			fmt.Printf("%s: %s: %s (%s)\n", level, change.Symbol, change.Message, change.Kind)
+		}
+	}
+
+	if n := changes.Breaking(); n > 0 {
+		return fmt.Errorf("found %d breaking changes", n)
+	}
+	return nil
+}
+
+// loadVersion loads a version of proto files given as proto files,
+// import paths or protoset files. Imports are only resolved from the given
+// import paths and the local file system.
+func loadVersion(args, importPaths []string, noCache bool) (proto.DescriptorSource, error) {
+	var protoArgs, protosets []string
+	for _, arg := range args {
+		switch filepath.Ext(arg) {
+		case ".protoset", ".pb":
+			protosets = append(protosets, arg)
+		default:
+			protoArgs = append(protoArgs, arg)
+		}
+	}
+
+	setSource, err := proto.NewProtosetSource(protosets...)
+	if err != nil {
+		return nil, err
+	}
+	fileDescs, err := parseProtoArgs(protoArgs, importPaths, noCache)
+	if err != nil {
+		return nil, err
+	}
+	// Protosets also contain the dependencies of their files, which must not
+	// be compared as part of the API.
+	setRoots := proto.NewFileSource(notImported(setSource.RootFiles())...)
+	return proto.NewCompositeSource(proto.NewFileSource(fileDescs...), setRoots), nil
+}
+
+// notImported returns the files that aren't imported by any of the others.
+func notImported(files []*desc.FileDescriptor) []*desc.FileDescriptor {
+	imported := make(map[string]struct{})
+	for _, file := range files {
+		for _, dep := range file.GetDependencies() {
+			imported[dep.GetName()] = struct{}{}
+		}
+	}
+	var roots []*desc.FileDescriptor
+	for _, file := range files {
+		if _, ok := imported[file.GetName()]; !ok {
+			roots = append(roots, file)
+		}
+	}
+	return roots
+}
+
+type breakingFlags struct {
+	verbose        bool // persistent
+	sourceFlags         // persistent
+	old            []string
+	new            []string
+	oldImportPaths []string
+	newImportPaths []string
+	output         string
+}
+
+func parseBreakingFlags(cmd *cobra.Command) (*breakingFlags, error) {
+	f := &breakingFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.proto) > 0 {
+		return nil, fmt.Errorf("flag --proto isn't supported by breaking, use --old and --new")
+	}
+	if len(f.importPaths) > 0 {
+		return nil, fmt.Errorf("flag --import-path isn't supported by breaking, use --old-import-path and --new-import-path")
+	}
+	f.old, err = cmd.Flags().GetStringSlice("old")
+	if err != nil {
+		return nil, err
+	}
+	f.new, err = cmd.Flags().GetStringSlice("new")
+	if err != nil {
+		return nil, err
+	}
+	f.oldImportPaths, err = cmd.Flags().GetStringSlice("old-import-path")
+	if err != nil {
+		return nil, err
+	}
+	f.newImportPaths, err = cmd.Flags().GetStringSlice("new-import-path")
+	if err != nil {
+		return nil, err
+	}
+	if len(f.old) == 0 || len(f.new) == 0 {
+		return nil, fmt.Errorf("flags --old and --new are required")
+	}
+	f.output, err = parseOutputFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the descriptor cache",
+		Long: prettify(`
+			Manage the cache of parsed proto files. Parsed proto files are stored
+			in $XDG_CACHE_HOME/ttrpcurl and reused as long as the proto files
+			and their imports don't change. Requests edited with --edit aren't
+			part of the cache, they are kept in $XDG_CONFIG_HOME/ttrpcurl.`),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newCacheClearCommand())
+
+	return cmd
+}
+
+func newCacheClearCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all entries from the descriptor cache",
+		Args:  cobra.NoArgs,
+		RunE:  runCacheClear,
+	}
+
+	return cmd
+}
+
+func runCacheClear(_ *cobra.Command, _ []string) error {
+	cacheDir, err := proto.DefaultCacheDir()
+	if err != nil {
+		return fmt.Errorf("getting cache directory: %w", err)
+	}
+	return proto.NewCache(cacheDir).Clear()
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/fullstorydev/grpcurl"
 	"github.com/jhump/protoreflect/desc"
@@ -19,8 +21,9 @@ func newDescribeCommand() *cobra.Command {
 			The symbol should be a fully-qualified name of a protobuf message,
 			enum, service, method, or field. If no symbol is given the descriptors
 			for all exposed or known services are shown`),
-		Args: cobra.MaximumNArgs(1),
-		RunE: runDescribe,
+		Args:        cobra.MaximumNArgs(1),
+		RunE:        runDescribe,
+		Annotations: map[string]string{sourceAnnotation: ""},
 	}
 
 	cmd.Flags().Bool("msg-template", false, prettify(`
@@ -28,6 +31,23 @@ func newDescribeCommand() *cobra.Command {
 	cmd.Flags().String("format", "json", prettify(`
 		Format in which the message template should be printed. The allowed values
 		are 'json' or 'text' for the protobuf text format.`))
+	cmd.Flags().Bool("deep", false, prettify(`
+		Also show all messages and enums the symbol references, directly or
+		transitively, each one once and after the types it references. The
+		types of messages packed into google.protobuf.Any fields are shown if
+		the comments of the field name them by type URL, like
+		'type.googleapis.com/pkg.Message'.`))
+	cmd.Flags().Int("depth", 0, prettify(`
+		Maximum number of references followed with --deep. Types directly
+		referenced by the symbol have depth 1. Zero means no limit.`))
+	cmd.Flags().Bool("exclude-well-known", false, prettify(`
+		Don't show well-known types like google.protobuf.Timestamp with --deep.`))
+	cmd.Flags().Bool("usages", false, prettify(`
+		Instead of describing the given message or enum, show the methods and
+		fields that use it, directly or through other messages.`))
+	cmd.MarkFlagsMutuallyExclusive("usages", "deep")
+	cmd.MarkFlagsMutuallyExclusive("usages", "msg-template")
+	addOutputFlag(cmd)
 
 	return cmd
 }
@@ -38,16 +58,18 @@ func runDescribe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
 
-	parser := proto.NewParser()
-	fileDescs, err := parser.ParseFiles(flags.proto...)
+	source, err := newSource(flags.sourceFlags)
 	if err != nil {
-		return fmt.Errorf("parsing proto files: %w", err)
+		return err
 	}
-	includedFileDescs, err := parser.WalkAndParse(protoIncludeFS, protoIncludePath)
-	if err != nil {
-		return fmt.Errorf("parsing included proto files: %w", err)
+
+	if flags.usages {
+		return printUsages(source, flags.output, args)
+	}
+
+	if flags.output != "text" {
+		return printDescription(source, flags.output, flags.walkOptions(source), args)
 	}
-	source := proto.NewSource(fileDescs, includedFileDescs)
 
 	printer := proto.NewPrinter()
 
@@ -65,7 +87,7 @@ func runDescribe(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	symbol, err := source.FindSymbol(args[0])
+	symbol, err := source.LookupSymbol(args[0])
 	if err != nil {
 		return fmt.Errorf("finding symbol: %w", err)
 	}
@@ -87,6 +109,34 @@ func runDescribe(cmd *cobra.Command, args []string) error {
 	fmt.Printf("%s is a %s:\n", symbol.GetFullyQualifiedName(), symbolType)
 	fmt.Printf("%s", proroSnip)
 
+	if msg, ok := symbol.(*desc.MessageDescriptor); ok && msg.IsExtendable() {
+		exts, err := source.AllExtensionsForType(msg.GetFullyQualifiedName())
+		if err != nil {
+			return fmt.Errorf("finding extensions: %w", err)
+		}
+		if len(exts) > 0 {
+			fmt.Println("\nExtensions:")
+		}
+		for _, ext := range exts {
+			fmt.Printf("  %s %s = %d; // %s\n", proto.FieldTypeName(ext), ext.GetFullyQualifiedName(), ext.GetNumber(), ext.GetFile().GetName())
+		}
+	}
+
+	if walkOpts := flags.walkOptions(source); walkOpts != nil {
+		for _, ref := range proto.ReferencedTypes(symbol, *walkOpts) {
+			refType, err := descriptorTypeStr(ref)
+			if err != nil {
+				return fmt.Errorf("getting descriptor type: %w", err)
+			}
+			refSnip, err := printer.PrintProtoToString(ref)
+			if err != nil {
+				return fmt.Errorf("printing proto to string: %w", err)
+			}
+			fmt.Printf("\n%s is a %s:\n", ref.GetFullyQualifiedName(), refType)
+			fmt.Printf("%s", refSnip)
+		}
+	}
+
 	if flags.msgTemplate {
 		tmpl, err := createTemplate(symbol, source, flags.format)
 		if err != nil {
@@ -99,6 +149,92 @@ func runDescribe(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printDescription prints the description of the given symbol, or of all
+// services, in a machine-readable format.
+func printDescription(source *proto.Source, format string, walkOpts *proto.WalkOptions, args []string) error {
+	describer := proto.Describer{Resolver: proto.NewTypeResolver(source)}
+	if len(args) == 0 {
+		return printStructured(os.Stdout, format, describer.Services(source.GetServices()))
+	}
+
+	symbol, err := source.LookupSymbol(args[0])
+	if err != nil {
+		return fmt.Errorf("finding symbol: %w", err)
+	}
+	description, err := describer.Describe(symbol)
+	if err != nil {
+		return fmt.Errorf("describing symbol: %w", err)
+	}
+	if msg, ok := symbol.(*desc.MessageDescriptor); ok && msg.IsExtendable() {
+		exts, err := source.AllExtensionsForType(msg.GetFullyQualifiedName())
+		if err != nil {
+			return fmt.Errorf("finding extensions: %w", err)
+		}
+		description.Extensions = describer.Extensions(exts)
+	}
+	if walkOpts != nil {
+		for _, ref := range proto.ReferencedTypes(symbol, *walkOpts) {
+			refDescription, err := describer.Describe(ref)
+			if err != nil {
+				return fmt.Errorf("describing symbol: %w", err)
+			}
+			description.References = append(description.References, refDescription)
+		}
+	}
+	return printStructured(os.Stdout, format, description)
+}
+
+// printUsages prints the methods and fields that use the given type.
+func printUsages(source *proto.Source, format string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("flag --usages requires a symbol")
+	}
+	symbol, err := source.LookupSymbol(args[0])
+	if err != nil {
+		return fmt.Errorf("finding symbol: %w", err)
+	}
+	switch symbol.(type) {
+	case *desc.MessageDescriptor, *desc.EnumDescriptor:
+	default:
+		return fmt.Errorf("symbol %s is neither a message nor an enum", symbol.GetFullyQualifiedName())
+	}
+
+	usages := proto.NewReferenceGraph(source.Files()).Usages(symbol.GetFullyQualifiedName())
+	if format != "text" {
+		return printStructured(os.Stdout, format, usages)
+	}
+
+	if len(usages.Methods) == 0 && len(usages.Fields) == 0 {
+		fmt.Printf("%s isn't used by any method or field\n", usages.Type)
+		return nil
+	}
+	fmt.Printf("%s is used by:\n", usages.Type)
+	if len(usages.Methods) > 0 {
+		fmt.Println("\nMethods:")
+		for _, usage := range usages.Methods {
+			fmt.Printf("  %s (%s%s)\n", usage.Name, usage.Role, formatVia(usage.Via, ", "))
+		}
+	}
+	if len(usages.Fields) > 0 {
+		fmt.Println("\nFields:")
+		for _, usage := range usages.Fields {
+			if len(usage.Via) == 0 {
+				fmt.Printf("  %s\n", usage.Name)
+				continue
+			}
+			fmt.Printf("  %s (%s)\n", usage.Name, formatVia(usage.Via, ""))
+		}
+	}
+	return nil
+}
+
+func formatVia(via []string, sep string) string {
+	if len(via) == 0 {
+		return ""
+	}
+	return sep + "via " + strings.Join(via, " > ")
+}
+
 func createTemplate(symbol desc.Descriptor, source *proto.Source, format string) (string, error) {
 	msg, ok := symbol.(*desc.MessageDescriptor)
 	if !ok {
@@ -121,10 +257,24 @@ func createTemplate(symbol desc.Descriptor, source *proto.Source, format string)
 }
 
 type describeFlags struct {
-	verbose     bool     // persistent
-	proto       []string // persistent
-	msgTemplate bool
-	format      string
+	verbose          bool // persistent
+	sourceFlags           // persistent
+	msgTemplate      bool
+	format           string
+	deep             bool
+	depth            int
+	excludeWellKnown bool
+	usages           bool
+	output           string
+}
+
+// walkOptions returns the options for walking referenced types, or nil if
+// they shouldn't be shown.
+func (f *describeFlags) walkOptions(source *proto.Source) *proto.WalkOptions {
+	if !f.deep {
+		return nil
+	}
+	return &proto.WalkOptions{MaxDepth: f.depth, ExcludeWellKnownTypes: f.excludeWellKnown, Source: source}
 }
 
 func parseDescribeFlags(cmd *cobra.Command) (*describeFlags, error) {
@@ -135,7 +285,7 @@ func parseDescribeFlags(cmd *cobra.Command) (*describeFlags, error) {
 	if err != nil {
 		return nil, err
 	}
-	f.proto, err = cmd.Flags().GetStringSlice("proto")
+	f.sourceFlags, err = parseSourceFlags(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +303,37 @@ func parseDescribeFlags(cmd *cobra.Command) (*describeFlags, error) {
 	default:
 		return nil, fmt.Errorf("unsupported format: %q", f.format)
 	}
+	f.deep, err = cmd.Flags().GetBool("deep")
+	if err != nil {
+		return nil, err
+	}
+	f.depth, err = cmd.Flags().GetInt("depth")
+	if err != nil {
+		return nil, err
+	}
+	if f.depth < 0 {
+		return nil, fmt.Errorf("flag --depth must not be negative")
+	}
+	f.excludeWellKnown, err = cmd.Flags().GetBool("exclude-well-known")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"depth", "exclude-well-known"} {
+		if !f.deep && cmd.Flags().Changed(name) {
+			return nil, fmt.Errorf("flag --%s is only supported with --deep", name)
+		}
+	}
+	f.usages, err = cmd.Flags().GetBool("usages")
+	if err != nil {
+		return nil, err
+	}
+	f.output, err = parseOutputFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if f.msgTemplate && f.output != "text" {
+		return nil, fmt.Errorf("flag --msg-template is only supported for --output=text")
+	}
 
 	return f, nil
 }
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newDocsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "docs [flags]",
+		Example: "ttrpcurl docs --proto=api.proto --out=docs",
+		Short:   "Generate an API reference from the given source",
+		Long: prettify(`
+			Generate a reference of all services of the given proto source and the
+			messages and enums they use, including the comments of the proto files.
+			In Markdown format, a directory with an index, a page per service and a
+			page of all types is written. In HTML format, a single file is written.`),
+		Args:        cobra.NoArgs,
+		RunE:        runDocs,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	cmd.Flags().String("format", "markdown", prettify(`
+		The format of the reference. The allowed values are 'markdown' or 'html'.`))
+	cmd.Flags().String("out", "", prettify(`
+		The directory the Markdown pages are written to, or the file the HTML
+		page is written to. The HTML page is written to stdout if not set.`))
+
+	return cmd
+}
+
+func runDocs(cmd *cobra.Command, _ []string) error {
+	flags, err := parseDocsFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+	data := newDocsData(source)
+
+	if flags.format == "html" {
+		if flags.out == "" {
+			return data.writeHTML(os.Stdout)
+		}
+		f, err := os.Create(flags.out)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		if err := data.writeHTML(f); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	return data.writeMarkdown(flags.out)
+}
+
+// docsData is the content of the reference.
+type docsData struct {
+	Services []proto.Service
+	Messages []proto.Message
+	Enums    []proto.Enum
+	// messages are the messages by name, to show the fields of the
+	// request and response of methods.
+	messages map[string]proto.Message
+}
+
+// newDocsData collects all services of source and the messages and enums
+// they reference. Nested types are listed on their own.
+func newDocsData(source *proto.Source) *docsData {
+	describer := proto.Describer{Resolver: proto.NewTypeResolver(source)}
+	data := &docsData{messages: make(map[string]proto.Message)}
+
+	types := make(map[string]desc.Descriptor)
+	for _, svc := range source.GetServices() {
+		data.Services = append(data.Services, describer.Service(svc))
+		for _, ref := range proto.ReferencedTypes(svc, proto.WalkOptions{KeepNestedTypes: true}) {
+			types[ref.GetFullyQualifiedName()] = ref
+		}
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch t := types[name].(type) {
+		case *desc.MessageDescriptor:
+			message := describer.Message(t)
+			message.Messages, message.Enums = nil, nil
+			data.Messages = append(data.Messages, message)
+			data.messages[name] = message
+		case *desc.EnumDescriptor:
+			data.Enums = append(data.Enums, describer.Enum(t))
+		}
+	}
+	return data
+}
+
+// documented reports whether the type with the given name has a section in
+// the reference.
+func (d *docsData) documented(name string) bool {
+	if _, ok := d.messages[name]; ok {
+		return true
+	}
+	for _, enum := range d.Enums {
+		if enum.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *docsData) message(name string) proto.Message {
+	return d.messages[name]
+}
+
+func (d *docsData) writeMarkdown(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("flag --out is required for markdown format")
+	}
+	tmpl, err := template.New("markdown").Funcs(template.FuncMap{
+		"link":      d.markdownLink,
+		"fieldType": d.markdownFieldType,
+		"cell":      markdownCell,
+		"firstLine": firstLine,
+		"shortName": shortName,
+		"message":   d.message,
+	}).Parse(markdownTemplates)
+	if err != nil {
+		return fmt.Errorf("parsing templates: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	write := func(name, tmplName string, data any) error {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", name, err)
+		}
+		defer f.Close()
+		if err := tmpl.ExecuteTemplate(f, tmplName, data); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+		return f.Close()
+	}
+
+	if err := write("index.md", "index", d); err != nil {
+		return err
+	}
+	for _, svc := range d.Services {
+		if err := write(svc.Name+".md", "service", svc); err != nil {
+			return err
+		}
+	}
+	return write("types.md", "types", d)
+}
+
+func (d *docsData) markdownLink(name string) string {
+	if !d.documented(name) {
+		return "`" + name + "`"
+	}
+	return fmt.Sprintf("[%s](types.md#%s)", name, name)
+}
+
+// markdownFieldType formats the type of a field. Map types are written as
+// code, linked to the type of their values.
+func (d *docsData) markdownFieldType(t string) string {
+	mt := parseMapType(t)
+	if mt == nil {
+		return d.markdownLink(t)
+	}
+	if !d.documented(mt.Value) {
+		return "`" + t + "`"
+	}
+	return fmt.Sprintf("[`%s`](types.md#%s)", t, mt.Value)
+}
+
+func (d *docsData) writeHTML(w io.Writer) error {
+	tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap{
+		"link":      d.htmlLink,
+		"mapType":   parseMapType,
+		"firstLine": firstLine,
+		"shortName": shortName,
+		"message":   d.message,
+	}).Parse(htmlTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	return tmpl.Execute(w, d)
+}
+
+func (d *docsData) htmlLink(name string) htmltemplate.HTML {
+	escaped := htmltemplate.HTMLEscapeString(name)
+	if !d.documented(name) {
+		return htmltemplate.HTML("<code>" + escaped + "</code>")
+	}
+	return htmltemplate.HTML(fmt.Sprintf(`<a href="#%s"><code>%s</code></a>`, escaped, escaped))
+}
+
+// mapType is the key and value type of a map type.
+type mapType struct {
+	Key   string
+	Value string
+}
+
+// parseMapType parses map types like 'map<string, pkg.Type>'. It returns nil
+// for other types.
+func parseMapType(t string) *mapType {
+	inner, ok := strings.CutPrefix(t, "map<")
+	if !ok {
+		return nil
+	}
+	key, value, _ := strings.Cut(strings.TrimSuffix(inner, ">"), ", ")
+	return &mapType{Key: key, Value: value}
+}
+
+// markdownCell makes s usable in a cell of a Markdown table.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+const markdownTemplates = `
+{{- define "index" -}}
+# API Reference
+
+## Services
+{{ range .Services }}
+- [{{ .Name }}]({{ .Name }}.md){{ with firstLine .Comments }}: {{ . }}{{ end }}
+{{- end }}
+
+## Types
+{{ range .Messages }}
+- {{ link .Name }}
+{{- end }}
+{{- range .Enums }}
+- {{ link .Name }}
+{{- end }}
+{{ end -}}
+
+{{- define "fields" -}}
+{{- if .Fields -}}
+| Field | Number | Type | Label | Description |
+| --- | --- | --- | --- | --- |
+{{- range .Fields }}
+| {{ .Name }} | {{ .Number }} | {{ fieldType .Type }} | {{ .Label }}{{ with .Oneof }} (oneof {{ . }}){{ end }} | {{ cell .Comments }} |
+{{- end }}
+{{- else -}}
+No fields.
+{{- end }}
+{{ end -}}
+
+{{- define "service" -}}
+# {{ .Name }}
+{{ with .Comments }}
+{{ . }}
+{{ end }}
+Defined in ` + "`{{ .File }}`" + `.
+
+| Method | Request | Response | Streaming | Description |
+| --- | --- | --- | --- | --- |
+{{- range .Methods }}
+| [{{ shortName .Name }}](#{{ shortName .Name }}) | {{ link .InputType }} | {{ link .OutputType }} | {{ .Streaming }} | {{ cell (firstLine .Comments) }} |
+{{- end }}
+{{ range .Methods }}
+## <a id="{{ shortName .Name }}"></a>{{ shortName .Name }}
+{{ with .Comments }}
+{{ . }}
+{{ end }}
+### Request: {{ link .InputType }}
+
+{{ template "fields" (message .InputType) }}
+### Response: {{ link .OutputType }}
+
+{{ template "fields" (message .OutputType) }}
+{{- end }}
+{{- end -}}
+
+{{- define "types" -}}
+# Types
+{{ range .Messages }}
+## <a id="{{ .Name }}"></a>{{ .Name }}
+{{ with .Comments }}
+{{ . }}
+{{ end }}
+{{ template "fields" . }}
+{{- end }}
+{{- range .Enums }}
+## <a id="{{ .Name }}"></a>{{ .Name }}
+{{ with .Comments }}
+{{ . }}
+{{ end }}
+| Name | Number | Description |
+| --- | --- | --- |
+{{- range .Values }}
+| {{ .Name }} | {{ .Number }} | {{ cell .Comments }} |
+{{- end }}
+{{ end }}
+{{- end -}}
+`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API Reference</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.comments { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>API Reference</h1>
+<h2>Services</h2>
+<ul>
+{{- range .Services }}
+<li><a href="#{{ .Name }}">{{ .Name }}</a>{{ with firstLine .Comments }}: {{ . }}{{ end }}</li>
+{{- end }}
+</ul>
+<h2>Types</h2>
+<ul>
+{{- range .Messages }}
+<li>{{ link .Name }}</li>
+{{- end }}
+{{- range .Enums }}
+<li>{{ link .Name }}</li>
+{{- end }}
+</ul>
+{{- define "fields" }}
+{{- if .Fields }}
+<table>
+<tr><th>Field</th><th>Number</th><th>Type</th><th>Label</th><th>Description</th></tr>
+{{- range .Fields }}
+<tr><td>{{ .Name }}</td><td>{{ .Number }}</td><td>{{ with mapType .Type }}map&lt;{{ .Key }}, {{ link .Value }}&gt;{{ else }}{{ link .Type }}{{ end }}</td><td>{{ .Label }}{{ with .Oneof }} (oneof {{ . }}){{ end }}</td><td class="comments">{{ .Comments }}</td></tr>
+{{- end }}
+</table>
+{{- else }}
+<p>No fields.</p>
+{{- end }}
+{{- end }}
+{{- range .Services }}
+{{- $service := . }}
+<h2 id="{{ .Name }}">{{ .Name }}</h2>
+{{- with .Comments }}
+<p class="comments">{{ . }}</p>
+{{- end }}
+<p>Defined in <code>{{ .File }}</code>.</p>
+<table>
+<tr><th>Method</th><th>Request</th><th>Response</th><th>Streaming</th><th>Description</th></tr>
+{{- range .Methods }}
+<tr><td><a href="#{{ .Name }}">{{ shortName .Name }}</a></td><td>{{ link .InputType }}</td><td>{{ link .OutputType }}</td><td>{{ .Streaming }}</td><td>{{ firstLine .Comments }}</td></tr>
+{{- end }}
+</table>
+{{- range .Methods }}
+<h3 id="{{ .Name }}">{{ .Name }}</h3>
+{{- with .Comments }}
+<p class="comments">{{ . }}</p>
+{{- end }}
+<h4>Request: {{ link .InputType }}</h4>
+{{- template "fields" (message .InputType) }}
+<h4>Response: {{ link .OutputType }}</h4>
+{{- template "fields" (message .OutputType) }}
+{{- end }}
+{{- end }}
+<h2>Types</h2>
+{{- range .Messages }}
+<h3 id="{{ .Name }}">{{ .Name }}</h3>
+{{- with .Comments }}
+<p class="comments">{{ . }}</p>
+{{- end }}
+{{- template "fields" . }}
+{{- end }}
+{{- range .Enums }}
+<h3 id="{{ .Name }}">{{ .Name }}</h3>
+{{- with .Comments }}
+<p class="comments">{{ . }}</p>
+{{- end }}
+<table>
+<tr><th>Name</th><th>Number</th><th>Description</th></tr>
+{{- range .Values }}
+<tr><td>{{ .Name }}</td><td>{{ .Number }}</td><td class="comments">{{ .Comments }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`
+
+type docsFlags struct {
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	format      string
+	out         string
+}
+
+func parseDocsFlags(cmd *cobra.Command) (*docsFlags, error) {
+	f := &docsFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.format, err = cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, err
+	}
+	switch f.format {
+	case "markdown":
+	case "html":
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", f.format)
+	}
+	f.out, err = cmd.Flags().GetString("out")
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
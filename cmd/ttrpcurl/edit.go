@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/katexochen/ttrpcurl/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// editRequest opens an editor on the request of the method and returns the
+// edited request once it is valid. The editor starts with the request that
+// was last edited for the method, or with a commented template of the input
+// type if there is none.
+func editRequest(source *proto.Source, method string, u proto.Unmarshaler) ([]byte, error) {
+	mth, err := source.FindMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := savedRequestPath(mth.GetFullyQualifiedName(), u.Format)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		tmpl, err := proto.CommentedTemplate(mth.GetInputType(), u.Format)
+		if err != nil {
+			return nil, fmt.Errorf("creating template: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(tmpl), 0o600); err != nil {
+			return nil, fmt.Errorf("writing template: %w", err)
+		}
+	}
+
+	if err := runEditor(path); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading edited request: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("request is empty, aborting")
+	}
+	if u.Format == "json" {
+		data = proto.StripJSONComments(data)
+	}
+
+	// The edited request is kept even if it is invalid, so it can be fixed
+	// with the next call. Requests of client streaming calls may consist of
+	// several messages.
+	reader := proto.NewMessageReader(bytes.NewReader(data), u)
+	for {
+		req := dynamicpb.NewMessage(mth.GetInputType().UnwrapMessage())
+		if err := reader.Next(req); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid request in %s: %w", path, err)
+		}
+	}
+	return data, nil
+}
+
+// savedRequestPath returns the path of the last edited request of a method,
+// which is stored in $XDG_CONFIG_HOME/ttrpcurl/requests. Unlike the cache,
+// edited requests can't be recreated, so they aren't removed by
+// 'ttrpcurl cache clear'.
+func savedRequestPath(method, format string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("getting config directory: %w", err)
+	}
+	dir := filepath.Join(configDir, "ttrpcurl", "requests")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating requests directory: %w", err)
+	}
+
+	ext := ".json"
+	switch format {
+	case "yaml":
+		ext = ".yaml"
+	case "text":
+		ext = ".txtpb"
+	}
+	return filepath.Join(dir, method+ext), nil
+}
+
+// runEditor opens the file in $VISUAL or $EDITOR, falling back to vi. The
+// editor command is run by the shell, so it may contain arguments.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor %q: %w", editor, err)
+	}
+	return nil
+}
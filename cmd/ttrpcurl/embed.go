@@ -2,12 +2,14 @@
 
 package main
 
-import "embed"
+import _ "embed"
 
-//go:embed protoinclude/*.proto
-var protoIncludeFS embed.FS
+// protoIncludeSet is the descriptor set of the proto files in protoinclude,
+// compiled by 'go generate'.
+//
+//go:embed protoinclude.protoset
+var protoIncludeSet []byte
 
-const (
-	protoIncludePath  = "protoinclude"
-	protoFlagRequired = false
-)
+// protoIncludeEmbedded is true if the binary is built with included proto
+// files. --proto is still required if protoinclude didn't contain any.
+const protoIncludeEmbedded = true
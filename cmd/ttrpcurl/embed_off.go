@@ -2,11 +2,6 @@
 
 package main
 
-import "embed"
+var protoIncludeSet []byte
 
-var protoIncludeFS embed.FS
-
-const (
-	protoIncludePath  = "."
-	protoFlagRequired = true
-)
+const protoIncludeEmbedded = false
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/katexochen/ttrpcurl/proto"
+)
+
+// TestIncludeSetUpToDate checks that the committed descriptor set matches the
+// proto files in protoinclude. The set may only be empty if protoinclude
+// doesn't contain proto files.
+func TestIncludeSetUpToDate(t *testing.T) {
+	files, err := proto.NewParser().WalkAndParse(os.DirFS("protoinclude"), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := proto.MarshalDescriptorSet(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("protoinclude.protoset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 0 && len(committed) == 0 {
+		t.Fatal("protoinclude.protoset is empty, but protoinclude contains proto files, run 'go generate ./cmd/ttrpcurl'")
+	}
+	if !bytes.Equal(set, committed) {
+		t.Error("protoinclude.protoset is out of date, run 'go generate ./cmd/ttrpcurl'")
+	}
+}
+
+// BenchmarkIncludeSource compares parsing the included proto files at startup
+// with loading the descriptor set precompiled by 'go generate'.
+func BenchmarkIncludeSource(b *testing.B) {
+	fsys := benchIncludeFS(b)
+
+	files, err := proto.NewParser().WalkAndParse(fsys, ".")
+	if err != nil {
+		b.Fatal(err)
+	}
+	set, err := proto.MarshalDescriptorSet(files...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := proto.NewFSSource(proto.NewParser(), fsys, "."); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("precompiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fdset, err := proto.UnmarshalDescriptorSet(set)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := proto.NewDescriptorSetSource(fdset); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// benchIncludeFS returns the proto files in protoinclude. If there are none,
+// a test proto with imports of well-known types is used instead.
+func benchIncludeFS(b *testing.B) fs.FS {
+	if matches, _ := filepath.Glob("protoinclude/*.proto"); len(matches) > 0 {
+		return os.DirFS("protoinclude")
+	}
+
+	testProto, err := os.ReadFile("../../hack/testserver/grpctest/test.proto")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return fstest.MapFS{
+		"test.proto": {Data: testProto},
+		"wkt.proto": {Data: []byte(`syntax = "proto3";
+
+package wkt;
+
+import "google/protobuf/any.proto";
+import "google/protobuf/descriptor.proto";
+import "google/protobuf/duration.proto";
+import "google/protobuf/empty.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/timestamp.proto";
+
+message WellKnownTypes {
+    google.protobuf.Any any = 1;
+    google.protobuf.FileDescriptorSet set = 2;
+    google.protobuf.Duration duration = 3;
+    google.protobuf.Empty empty = 4;
+    google.protobuf.Struct struct = 5;
+    google.protobuf.Timestamp timestamp = 6;
+}
+`)},
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func newEncodeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "encode [flags] <message>",
+		Example: `ttrpcurl encode --proto=api.proto --encoding=hex -d '{"id": "foo"}' package.Message`,
+		Short:   "Encode messages into the protobuf wire format",
+		Long: prettify(`
+			Encode messages of the given type from JSON, YAML or text format into
+			the protobuf wire format, without calling a server. Multiple messages
+			are encoded as stream of length-delimited messages if --delimited is
+			set.`),
+		Args:        cobra.ExactArgs(1),
+		RunE:        runEncode,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	addCodecFlags(cmd, "input", "output")
+
+	return cmd
+}
+
+func newDecodeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "decode [flags] <message>",
+		Example: "ttrpcurl decode --proto=api.proto --encoding=base64 -d CgNmb28= package.Message",
+		Short:   "Decode messages from the protobuf wire format",
+		Long: prettify(`
+			Decode messages of the given type from the protobuf wire format into
+			JSON, YAML or text format, without calling a server. If --delimited is
+			set, the input is a stream of length-delimited messages.`),
+		Args:        cobra.ExactArgs(1),
+		RunE:        runDecode,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	addCodecFlags(cmd, "output", "input")
+
+	return cmd
+}
+
+// addCodecFlags adds the flags of the encode and decode commands. The
+// messages in JSON, YAML or text format are the formatDir, the encoded messages
+// the encodingDir of the command.
+func addCodecFlags(cmd *cobra.Command, formatDir, encodingDir string) {
+	cmd.Flags().StringP("data", "d", "", prettify(`
+		The input data. If not set or '@', the input is read from stdin.`))
+	cmd.Flags().String("format", "json", prettify(fmt.Sprintf(`
+		The format of the %s messages. The allowed values are 'json', 'yaml'
+		or 'text'.
+		Multiple messages are handled as with the format flag of calls.`, formatDir)))
+	cmd.Flags().String("encoding", "binary", prettify(fmt.Sprintf(`
+		The encoding of the %s wire format data. The allowed values are
+		'binary', 'base64' or 'hex'.`, encodingDir)))
+	cmd.Flags().Bool("delimited", false, prettify(`
+		The wire format data is a stream of messages, each prefixed with its
+		size as varint.`))
+}
+
+func runEncode(cmd *cobra.Command, args []string) error {
+	flags, err := parseCodecFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+	md, err := source.FindMessage(args[0])
+	if err != nil {
+		return fmt.Errorf("finding message: %w", err)
+	}
+	data, err := readCodecInput(flags.data)
+	if err != nil {
+		return err
+	}
+
+	resolver := proto.NewTypeResolver(source)
+	reader := proto.NewMessageReader(bytes.NewReader(data), proto.Unmarshaler{Format: flags.format, Resolver: resolver})
+	var messages []*dynamicpb.Message
+	for {
+		mes := dynamicpb.NewMessage(md.UnwrapMessage())
+		if err := reader.Next(mes); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		messages = append(messages, mes)
+	}
+
+	var out bytes.Buffer
+	if !flags.delimited {
+		if len(messages) > 1 {
+			return fmt.Errorf("input contains %d messages, use --delimited to encode a stream", len(messages))
+		}
+		// Empty input is encoded as empty message.
+		mes := dynamicpb.NewMessage(md.UnwrapMessage())
+		if len(messages) == 1 {
+			mes = messages[0]
+		}
+		b, err := proto.Marshaler{Format: "binary"}.Marshal(mes)
+		if err != nil {
+			return err
+		}
+		out.Write(b)
+	} else {
+		writer := proto.NewMessageWriter(&out, proto.Marshaler{Format: "binary"})
+		for _, mes := range messages {
+			if err := writer.Write(mes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeEncoded(os.Stdout, flags.encoding, out.Bytes())
+}
+
+func runDecode(cmd *cobra.Command, args []string) error {
+	flags, err := parseCodecFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+	md, err := source.FindMessage(args[0])
+	if err != nil {
+		return fmt.Errorf("finding message: %w", err)
+	}
+	data, err := readCodecInput(flags.data)
+	if err != nil {
+		return err
+	}
+	data, err = decodeEncoding(flags.encoding, data)
+	if err != nil {
+		return err
+	}
+
+	resolver := proto.NewTypeResolver(source)
+	writer := proto.NewMessageWriter(os.Stdout, proto.Marshaler{Multiline: true, Format: flags.format, Resolver: resolver})
+	if !flags.delimited {
+		mes := dynamicpb.NewMessage(md.UnwrapMessage())
+		if err := (proto.Unmarshaler{Format: "binary", Resolver: resolver}).Unmarshal(data, mes); err != nil {
+			return err
+		}
+		return writer.Write(mes)
+	}
+
+	reader := proto.NewMessageReader(bytes.NewReader(data), proto.Unmarshaler{Format: "binary", Resolver: resolver})
+	for {
+		mes := dynamicpb.NewMessage(md.UnwrapMessage())
+		if err := reader.Next(mes); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := writer.Write(mes); err != nil {
+			return err
+		}
+	}
+}
+
+func readCodecInput(data string) ([]byte, error) {
+	if data != "" && data != "@" {
+		return []byte(data), nil
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("reading data from stdin: %w", err)
+	}
+	return b, nil
+}
+
+// writeEncoded writes the wire format data b to w in the given encoding.
+// Text encodings are terminated by a newline.
+func writeEncoded(w io.Writer, encoding string, b []byte) error {
+	var err error
+	switch encoding {
+	case "base64":
+		_, err = fmt.Fprintln(w, base64.StdEncoding.EncodeToString(b))
+	case "hex":
+		_, err = fmt.Fprintln(w, hex.EncodeToString(b))
+	default:
+		_, err = w.Write(b)
+	}
+	return err
+}
+
+// decodeEncoding decodes wire format data in the given encoding. Whitespace
+// in text encodings is ignored.
+func decodeEncoding(encoding string, b []byte) ([]byte, error) {
+	if encoding == "binary" {
+		return b, nil
+	}
+	s := strings.Join(strings.Fields(string(b)), "")
+	var decoded []byte
+	var err error
+	if encoding == "base64" {
+		decoded, err = base64.StdEncoding.DecodeString(s)
+	} else {
+		decoded, err = hex.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", encoding, err)
+	}
+	return decoded, nil
+}
+
+type codecFlags struct {
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	data        string
+	format      string
+	encoding    string
+	delimited   bool
+}
+
+func parseCodecFlags(cmd *cobra.Command) (*codecFlags, error) {
+	f := &codecFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.data, err = cmd.Flags().GetString("data")
+	if err != nil {
+		return nil, err
+	}
+	f.format, err = cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, err
+	}
+	switch f.format {
+	case "json":
+	case "yaml":
+	case "text":
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", f.format)
+	}
+	f.encoding, err = cmd.Flags().GetString("encoding")
+	if err != nil {
+		return nil, err
+	}
+	switch f.encoding {
+	case "binary":
+	case "base64":
+	case "hex":
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %q", f.encoding)
+	}
+	f.delimited, err = cmd.Flags().GetBool("delimited")
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,6 @@
+package main
+
+// The proto files in protoinclude are compiled into a descriptor set, which is
+// embedded in the binary when building with the 'embed' tag. The descriptor
+// set is committed, so it must be regenerated when protoinclude changes.
+//go:generate go run ../../hack/protosetgen -dir protoinclude -out protoinclude.protoset
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newGraphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "graph [flags] [symbol]",
+		Example: "ttrpcurl graph --proto=api.proto --format=mermaid package.Service",
+		Short:   "Render the graph of services, methods and types",
+		Long: prettify(`
+			Render the services, methods, messages and enums of the given proto source
+			and the references between them as Graphviz DOT or Mermaid graph.
+			If a symbol is given, only the symbols reachable from it are shown.`),
+		Args:        cobra.MaximumNArgs(1),
+		RunE:        runGraph,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	cmd.Flags().String("format", "dot", prettify(`
+		The format of the graph. The allowed values are 'dot' or 'mermaid'.`))
+	cmd.Flags().StringSlice("package", []string{}, prettify(`
+		Only show symbols of the given package and its sub-packages. May specify
+		more than one via repeated use of the flag.`))
+	cmd.Flags().Bool("fields", false, prettify(`
+		Show an edge labeled with the field name for every field of a message,
+		instead of one edge per referenced type.`))
+
+	return cmd
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	flags, err := parseGraphFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+
+	opts := proto.GraphOptions{Packages: flags.packages, Fields: flags.fields}
+	if len(args) == 1 {
+		opts.Root, err = source.LookupSymbol(args[0])
+		if err != nil {
+			return fmt.Errorf("finding symbol: %w", err)
+		}
+	}
+	graph := proto.NewTypeGraph(source.Files(), opts)
+
+	if flags.format == "mermaid" {
+		writeMermaid(os.Stdout, graph)
+	} else {
+		writeDOT(os.Stdout, graph)
+	}
+	return nil
+}
+
+var dotShapes = map[string]string{
+	"service": "component",
+	"method":  "ellipse",
+	"message": "box",
+	"enum":    "hexagon",
+}
+
+func writeDOT(w io.Writer, graph *proto.TypeGraph) {
+	fmt.Fprintln(w, "digraph protos {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(w, "  %q [shape=%s];\n", node.Name, dotShapes[node.Kind])
+	}
+	for _, edge := range graph.Edges {
+		if edge.Label == "" {
+			fmt.Fprintf(w, "  %q -> %q;\n", edge.From, edge.To)
+			continue
+		}
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Label)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// mermaidShapes are the opening and closing brackets of a node's shape.
+var mermaidShapes = map[string][2]string{
+	"service": {"[[", "]]"},
+	"method":  {"(", ")"},
+	"message": {"[", "]"},
+	"enum":    {"{{", "}}"},
+}
+
+func writeMermaid(w io.Writer, graph *proto.TypeGraph) {
+	// Mermaid node IDs can't contain dots, so nodes are numbered.
+	ids := make(map[string]string, len(graph.Nodes))
+	fmt.Fprintln(w, "flowchart LR")
+	for i, node := range graph.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+		shape := mermaidShapes[node.Kind]
+		fmt.Fprintf(w, "  %s%s\"%s\"%s\n", ids[node.Name], shape[0], node.Name, shape[1])
+	}
+	for _, edge := range graph.Edges {
+		if edge.Label == "" {
+			fmt.Fprintf(w, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+			continue
+		}
+		fmt.Fprintf(w, "  %s -->|%s| %s\n", ids[edge.From], edge.Label, ids[edge.To])
+	}
+}
+
+type graphFlags struct {
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	format      string
+	packages    []string
+	fields      bool
+}
+
+func parseGraphFlags(cmd *cobra.Command) (*graphFlags, error) {
+	f := &graphFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.format, err = cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, err
+	}
+	switch f.format {
+	case "dot":
+	case "mermaid":
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", f.format)
+	}
+	f.packages, err = cmd.Flags().GetStringSlice("package")
+	if err != nil {
+		return nil, err
+	}
+	f.fields, err = cmd.Flags().GetBool("fields")
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
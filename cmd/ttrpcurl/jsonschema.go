@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newJSONSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "jsonschema [flags] [symbol]",
+		Example: "ttrpcurl jsonschema --proto=api.proto package.Service.Method > request.schema.json",
+		Short:   "Generate JSON Schemas of request messages",
+		Long: prettify(`
+			Generate a JSON Schema (draft 2020-12) of the JSON representation of a
+			message, which can be used by editors to validate and complete request
+			data. Like protojson, the schema accepts enums by name or number,
+			integers as strings and null for unset fields. If the symbol is a
+			message, its schema is printed. If it is a
+			method, the schema of its input type is printed. If it is a service or
+			no symbol is given, a schema of the input type of every method of the
+			service or source is written to the --out directory, named after the
+			method.`),
+		Args:        cobra.MaximumNArgs(1),
+		RunE:        runJSONSchema,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	cmd.Flags().String("out", "", prettify(`
+		The directory the schemas of multiple methods are written to.`))
+
+	return cmd
+}
+
+func runJSONSchema(cmd *cobra.Command, args []string) error {
+	flags, err := parseJSONSchemaFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+
+	methods := []*desc.MethodDescriptor{}
+	if len(args) == 0 {
+		for _, svc := range source.GetServices() {
+			methods = append(methods, svc.GetMethods()...)
+		}
+	} else {
+		symbol, err := source.LookupSymbol(args[0])
+		if err != nil {
+			return fmt.Errorf("finding symbol: %w", err)
+		}
+		switch symbol := symbol.(type) {
+		case *desc.MessageDescriptor:
+			return printStructured(os.Stdout, "json", (&proto.SchemaGenerator{}).Document(symbol))
+		case *desc.MethodDescriptor:
+			return printStructured(os.Stdout, "json", (&proto.SchemaGenerator{}).Document(symbol.GetInputType()))
+		case *desc.ServiceDescriptor:
+			methods = symbol.GetMethods()
+		default:
+			return fmt.Errorf("symbol %s isn't a message, method or service", args[0])
+		}
+	}
+
+	if flags.out == "" {
+		return fmt.Errorf("flag --out is required to generate the schemas of multiple methods")
+	}
+	if err := os.MkdirAll(flags.out, 0o755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	for _, method := range methods {
+		if err := writeSchemaFile(flags.out, method); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSchemaFile writes the schema of the input type of method to dir.
+func writeSchemaFile(dir string, method *desc.MethodDescriptor) error {
+	name := method.GetFullyQualifiedName() + ".json"
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	defer f.Close()
+	if err := printStructured(f, "json", (&proto.SchemaGenerator{}).Document(method.GetInputType())); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+type jsonSchemaFlags struct {
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	out         string
+}
+
+func parseJSONSchemaFlags(cmd *cobra.Command) (*jsonSchemaFlags, error) {
+	f := &jsonSchemaFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.out, err = cmd.Flags().GetString("out")
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
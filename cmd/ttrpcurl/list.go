@@ -2,7 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
 
+	"github.com/jhump/protoreflect/desc"
 	"github.com/katexochen/ttrpcurl/proto"
 	"github.com/spf13/cobra"
 )
@@ -17,9 +22,20 @@ func newListCommand() *cobra.Command {
 			If the symbol is a fully-qualified name of a protobuf service, formatted
 			like '[package.]service' or '[package/]service', the methods of that service
 			are listed. If no symbol is given, all available services are listed.`),
-		RunE: runList,
+		RunE:        runList,
+		Annotations: map[string]string{sourceAnnotation: ""},
 	}
 
+	cmd.Flags().BoolP("long", "l", false, prettify(`
+		Use a long listing format. Instead of service names, the methods are
+		listed with their streaming kind, signature and the file they are
+		defined in. Deprecated methods are marked as such.`))
+	cmd.Flags().Bool("files", false, prettify(`
+		List the loaded proto files and the tree of their imports instead of
+		services.`))
+	cmd.MarkFlagsMutuallyExclusive("long", "files")
+	addOutputFlag(cmd)
+
 	return cmd
 }
 
@@ -29,40 +45,149 @@ func runList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("parse flags: %w", err)
 	}
 
-	parser := proto.NewParser()
-	fileDescs, err := parser.ParseFiles(flags.proto...)
+	source, err := newSource(flags.sourceFlags)
 	if err != nil {
-		return fmt.Errorf("parsing proto files: %w", err)
+		return err
 	}
-	includedFileDescs, err := parser.WalkAndParse(protoIncludeFS, protoIncludePath)
-	if err != nil {
-		return fmt.Errorf("parsing included proto files: %w", err)
+
+	if flags.files {
+		if len(args) > 0 {
+			return fmt.Errorf("flag --files doesn't accept a symbol")
+		}
+		if flags.output != "text" {
+			describer := proto.Describer{Resolver: proto.NewTypeResolver(source)}
+			return printStructured(os.Stdout, flags.output, describer.Files(source.Files()))
+		}
+		printImportTree(os.Stdout, source.Files())
+		return nil
 	}
-	source := proto.NewSource(fileDescs, includedFileDescs)
 
+	if flags.output != "text" {
+		return printList(source, flags.output, args)
+	}
+
+	var services []*desc.ServiceDescriptor
 	switch len(args) {
 	case 0:
-		for _, svc := range source.GetServices() {
-			fmt.Println(svc.GetFullyQualifiedName())
+		services = source.GetServices()
+		if !flags.long {
+			for _, svc := range services {
+				fmt.Println(svc.GetFullyQualifiedName())
+			}
+			return nil
 		}
-		return nil
 	case 1:
 		svc, err := source.FindService(args[0])
 		if err != nil {
 			return fmt.Errorf("finding service: %w", err)
 		}
-		for _, method := range svc.GetMethods() {
-			fmt.Println(method.GetFullyQualifiedName())
+		services = []*desc.ServiceDescriptor{svc}
+	default:
+		return fmt.Errorf("too many arguments")
+	}
+
+	if flags.long {
+		return printMethodsLong(os.Stdout, services)
+	}
+	for _, method := range services[0].GetMethods() {
+		fmt.Println(method.GetFullyQualifiedName())
+	}
+	return nil
+}
+
+// printList prints the services, or the methods of the given service, in
+// a machine-readable format.
+func printList(source *proto.Source, format string, args []string) error {
+	describer := proto.Describer{Resolver: proto.NewTypeResolver(source)}
+	switch len(args) {
+	case 0:
+		return printStructured(os.Stdout, format, describer.Services(source.GetServices()))
+	case 1:
+		svc, err := source.FindService(args[0])
+		if err != nil {
+			return fmt.Errorf("finding service: %w", err)
 		}
-		return nil
+		return printStructured(os.Stdout, format, describer.Service(svc))
 	default:
 		return fmt.Errorf("too many arguments")
 	}
 }
 
+// printMethodsLong prints one line per method of the given services, like
+// 'server  pkg.Service.Method(pkg.Request) returns (stream pkg.Response)  file.proto'.
+// Deprecated methods are marked with '[deprecated]' after the file.
+func printMethodsLong(w io.Writer, services []*desc.ServiceDescriptor) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, svc := range services {
+		for _, method := range svc.GetMethods() {
+			input := method.GetInputType().GetFullyQualifiedName()
+			if method.IsClientStreaming() {
+				input = "stream " + input
+			}
+			output := method.GetOutputType().GetFullyQualifiedName()
+			if method.IsServerStreaming() {
+				output = "stream " + output
+			}
+			var deprecated string
+			if method.GetMethodOptions().GetDeprecated() || svc.GetServiceOptions().GetDeprecated() {
+				deprecated = " [deprecated]"
+			}
+			fmt.Fprintf(tw, "%s\t%s(%s) returns (%s)\t%s%s\n", proto.StreamingKind(method),
+				method.GetFullyQualifiedName(), input, output, method.GetFile().GetName(), deprecated)
+		}
+	}
+	return tw.Flush()
+}
+
+// printImportTree prints the given files as trees of their imports. Only
+// files that aren't imported by another file are printed at the top level.
+// Files whose imports have already been printed are marked with '(*)'.
+func printImportTree(w io.Writer, files []*desc.FileDescriptor) {
+	imported := make(map[string]struct{})
+	for _, file := range files {
+		for _, dep := range file.GetDependencies() {
+			imported[dep.GetName()] = struct{}{}
+		}
+	}
+	var roots []*desc.FileDescriptor
+	for _, file := range files {
+		if _, ok := imported[file.GetName()]; !ok {
+			roots = append(roots, file)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].GetName() < roots[j].GetName() })
+
+	printed := make(map[string]struct{})
+	var printTree func(file *desc.FileDescriptor, prefix string)
+	printTree = func(file *desc.FileDescriptor, prefix string) {
+		deps := file.GetDependencies()
+		for i, dep := range deps {
+			branch, indent := "├── ", "│   "
+			if i == len(deps)-1 {
+				branch, indent = "└── ", "    "
+			}
+			_, seen := printed[dep.GetName()]
+			if seen && len(dep.GetDependencies()) > 0 {
+				fmt.Fprintf(w, "%s%s%s (*)\n", prefix, branch, dep.GetName())
+				continue
+			}
+			printed[dep.GetName()] = struct{}{}
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, dep.GetName())
+			printTree(dep, prefix+indent)
+		}
+	}
+	for _, root := range roots {
+		fmt.Fprintln(w, root.GetName())
+		printTree(root, "")
+	}
+}
+
 type listFlags struct {
-	verbose bool     // persistent
-	proto   []string // persistent
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	long        bool
+	files       bool
+	output      string
 }
 
 func parseListFlags(cmd *cobra.Command) (*listFlags, error) {
@@ -73,10 +198,26 @@ func parseListFlags(cmd *cobra.Command) (*listFlags, error) {
 	if err != nil {
 		return nil, err
 	}
-	f.proto, err = cmd.Flags().GetStringSlice("proto")
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.long, err = cmd.Flags().GetBool("long")
+	if err != nil {
+		return nil, err
+	}
+	f.files, err = cmd.Flags().GetBool("files")
+	if err != nil {
+		return nil, err
+	}
+	f.output, err = parseOutputFlag(cmd)
 	if err != nil {
 		return nil, err
 	}
+	// The json and yaml output always includes the details of methods.
+	if f.long && f.output != "text" {
+		return nil, fmt.Errorf("flag --long is only supported for --output=text")
+	}
 
 	return f, nil
 }
@@ -28,12 +28,20 @@ func main() {
 func run() error {
 	rootCmd := newRootCmd()
 
-	rootCmd.PersistentPreRun = preRunRoot
+	rootCmd.PersistentPreRunE = preRunRoot
 	rootCmd.SetOut(os.Stderr)
 
 	rootCmd.AddCommand(
 		newListCommand(),
 		newDescribeCommand(),
+		newGraphCommand(),
+		newDocsCommand(),
+		newJSONSchemaCommand(),
+		newOpenAPICommand(),
+		newBreakingCommand(),
+		newEncodeCommand(),
+		newDecodeCommand(),
+		newCacheCommand(),
 	)
 
 	rootCmd.Version = version
@@ -87,8 +95,13 @@ func signalContext(ctx context.Context, sig os.Signal) (context.Context, context
 	return sigCtx, cancelFunc
 }
 
-func preRunRoot(cmd *cobra.Command, _ []string) {
+func preRunRoot(cmd *cobra.Command, _ []string) error {
+	// Errors of flags are reported with usage, like cobra does.
+	if err := requireProtoFlag(cmd); err != nil {
+		return err
+	}
 	cmd.SilenceUsage = true
+	return nil
 }
 
 func prettify(docString string) string {
@@ -108,9 +121,3 @@ func prettify(docString string) string {
 
 	return strings.Join(parts[:j], "\n")
 }
-
-func must(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
@@ -7,12 +7,19 @@ import (
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/katexochen/ttrpcurl/hack/testserver/grpctest"
+	"github.com/katexochen/ttrpcurl/proto"
 	"github.com/rogpeppe/go-internal/testscript"
 )
 
-const replaceWithGrpcurl = "REPLACE_WITH_GRPCURL"
+const (
+	replaceWithGrpcurl = "REPLACE_WITH_GRPCURL"
+	// includeProtosDir is the directory of proto files used as included
+	// proto files, which are otherwise only part of the embed build.
+	includeProtosDir = "TTRPCURL_TEST_PROTOINCLUDE"
+)
 
 var (
 	update        = flag.Bool("u", false, "update testscript output files")
@@ -69,12 +76,31 @@ func TestClientServer(t *testing.T) {
 
 	testscript.Run(t, testscript.Params{
 		Dir:                 filepath.Join("testdata", "script", "clientserver"),
+		Cmds:                map[string]func(ts *testscript.TestScript, neg bool, args []string){"waitfile": waitFile},
 		UpdateScripts:       *update,
 		RequireUniqueNames:  true,
 		RequireExplicitExec: true,
 	})
 }
 
+// waitFile waits until the given file exists, e.g. the socket of a server
+// started in the background.
+func waitFile(ts *testscript.TestScript, neg bool, args []string) {
+	if neg {
+		ts.Fatalf("unsupported: ! waitfile")
+	}
+	if len(args) != 1 {
+		ts.Fatalf("usage: waitfile file")
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(ts.MkAbs(args[0])); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	ts.Fatalf("timeout waiting for %s", args[0])
+}
+
 func setupEnv(envVars map[string]string) func(e *testscript.Env) error {
 	return func(e *testscript.Env) error {
 		for k, v := range envVars {
@@ -98,6 +124,17 @@ func ttrpcurlMain() int {
 	if os.Getenv(replaceWithGrpcurl) == "true" {
 		return grpcurlMain()
 	}
+	if dir := os.Getenv(includeProtosDir); dir != "" {
+		files, err := proto.NewParser().WalkAndParse(os.DirFS(dir), ".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parsing included proto files: %v\n", err)
+			return 1
+		}
+		if protoIncludeSet, err = proto.MarshalDescriptorSet(files...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
 	if err := run(); err != nil {
 		return 1
 	}
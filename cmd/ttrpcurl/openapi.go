@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+func newOpenAPICommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "openapi [flags] [service]",
+		Example: "ttrpcurl openapi --proto=api.proto --out=openapi.yaml package.Service",
+		Short:   "Generate an OpenAPI description of the given services",
+		Long: prettify(`
+			Generate an OpenAPI 3.1 document of the services of the given proto
+			source, or only of the given service. Every method is described as
+			'POST /package.Service/Method' with request and response bodies in
+			the JSON representation of the input and output type. Streaming
+			methods are marked with the x-ttrpc-streaming extension.`),
+		Args:        cobra.MaximumNArgs(1),
+		RunE:        runOpenAPI,
+		Annotations: map[string]string{sourceAnnotation: ""},
+	}
+
+	cmd.Flags().String("format", "yaml", prettify(`
+		The format of the document. The allowed values are 'yaml' or 'json'.`))
+	cmd.Flags().String("out", "", prettify(`
+		The file the document is written to. The document is written to stdout
+		if not set.`))
+	cmd.Flags().String("title", "ttrpc API", prettify(`
+		The title of the API in the document.`))
+	cmd.Flags().String("api-version", "1.0.0", prettify(`
+		The version of the API in the document.`))
+
+	return cmd
+}
+
+func runOpenAPI(cmd *cobra.Command, args []string) error {
+	flags, err := parseOpenAPIFlags(cmd)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	source, err := newSource(flags.sourceFlags)
+	if err != nil {
+		return err
+	}
+
+	services := source.GetServices()
+	if len(args) == 1 {
+		svc, err := source.FindService(args[0])
+		if err != nil {
+			return fmt.Errorf("finding service: %w", err)
+		}
+		services = []*desc.ServiceDescriptor{svc}
+	}
+	doc := proto.NewOpenAPIDocument(services, proto.OpenAPIInfo{Title: flags.title, Version: flags.apiVersion})
+
+	if flags.out == "" {
+		return printStructured(os.Stdout, flags.format, doc)
+	}
+	f, err := os.Create(flags.out)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer f.Close()
+	if err := printStructured(f, flags.format, doc); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+type openAPIFlags struct {
+	verbose     bool // persistent
+	sourceFlags      // persistent
+	format      string
+	out         string
+	title       string
+	apiVersion  string
+}
+
+func parseOpenAPIFlags(cmd *cobra.Command) (*openAPIFlags, error) {
+	f := &openAPIFlags{}
+
+	var err error
+	f.verbose, err = cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return nil, err
+	}
+	f.sourceFlags, err = parseSourceFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	f.format, err = cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, err
+	}
+	switch f.format {
+	case "yaml":
+	case "json":
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", f.format)
+	}
+	f.out, err = cmd.Flags().GetString("out")
+	if err != nil {
+		return nil, err
+	}
+	f.title, err = cmd.Flags().GetString("title")
+	if err != nil {
+		return nil, err
+	}
+	f.apiVersion, err = cmd.Flags().GetString("api-version")
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// addOutputFlag adds the --output flag of commands that can print their
+// result in a machine-readable format.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", "text", prettify(`
+		The output format. The allowed values are 'text', 'json' or 'yaml'.
+		The schema of the json and yaml output is documented in the
+		ttrpcurl/proto package.`))
+}
+
+func parseOutputFlag(cmd *cobra.Command) (string, error) {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", err
+	}
+	switch output {
+	case "text", "json", "yaml":
+		return output, nil
+	default:
+		return "", fmt.Errorf("unsupported output format: %q", output)
+	}
+}
+
+// printStructured writes v to w in the given machine-readable format.
+func printStructured(w io.Writer, format string, v any) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unsupported output format: %q", format)
+	}
+}
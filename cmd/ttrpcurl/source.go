@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/katexochen/ttrpcurl/proto"
+	"github.com/spf13/cobra"
+)
+
+// sourceFlags are the persistent flags that select the proto source.
+type sourceFlags struct {
+	proto          []string
+	importPaths    []string
+	noCache        bool
+	failOnConflict bool
+}
+
+// sourceAnnotation marks commands that load the proto files given by --proto,
+// so the flag is required for them.
+const sourceAnnotation = "ttrpcurl/source"
+
+// requireProtoFlag marks --proto as required for commands that load proto
+// files, and lets cobra validate it. The flag is shared by all commands, so
+// it can only be marked once the command is known.
+func requireProtoFlag(cmd *cobra.Command) error {
+	if !protoFlagRequired() {
+		return nil
+	}
+	if _, ok := cmd.Annotations[sourceAnnotation]; !ok {
+		return nil
+	}
+	if raw, err := cmd.Flags().GetBool("raw"); err == nil && raw {
+		return nil
+	}
+	if err := cmd.MarkFlagRequired("proto"); err != nil {
+		return err
+	}
+	return cmd.ValidateRequiredFlags()
+}
+
+// protoFlagRequired reports whether --proto is required, which is the case
+// unless the binary embeds a non-empty set of included proto files. An empty
+// FileDescriptorSet is encoded as zero bytes.
+func protoFlagRequired() bool {
+	return !protoIncludeEmbedded || len(protoIncludeSet) == 0
+}
+
+func parseSourceFlags(cmd *cobra.Command) (sourceFlags, error) {
+	f := sourceFlags{}
+
+	var err error
+	f.proto, err = cmd.Flags().GetStringSlice("proto")
+	if err != nil {
+		return f, err
+	}
+	f.importPaths, err = cmd.Flags().GetStringSlice("import-path")
+	if err != nil {
+		return f, err
+	}
+	f.noCache, err = cmd.Flags().GetBool("no-cache")
+	if err != nil {
+		return f, err
+	}
+	f.failOnConflict, err = cmd.Flags().GetBool("fail-on-conflict")
+	if err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// newSource loads the given proto files and the included proto files into a
+// single source. Symbols from the given proto files take precedence over
+// the included ones.
+func newSource(flags sourceFlags) (*proto.Source, error) {
+	fileDescs, err := parseProtoArgs(flags.proto, flags.importPaths, flags.noCache)
+	if err != nil {
+		return nil, err
+	}
+	includeSource, err := newIncludeSource()
+	if err != nil {
+		return nil, fmt.Errorf("loading included proto files: %w", err)
+	}
+
+	userSource := proto.NewFileSource(fileDescs...)
+	if err := checkConflicts(flags.failOnConflict, userSource, includeSource); err != nil {
+		return nil, err
+	}
+
+	return proto.NewSource(proto.NewCompositeSource(userSource, includeSource)), nil
+}
+
+// parseProtoArgs parses the given proto files. Directories, archives and
+// module references are expanded to all proto files they contain.
+func parseProtoArgs(protoArgs, importPaths []string, noCache bool) ([]*desc.FileDescriptor, error) {
+	parser := proto.NewParser()
+	for _, importPath := range importPaths {
+		if _, err := parser.AddImportPath(importPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var protoFiles []string
+	for _, protoArg := range protoArgs {
+		if !proto.IsImportPath(protoArg) {
+			protoFiles = append(protoFiles, protoArg)
+			continue
+		}
+		filenames, err := parser.AddImportPath(protoArg)
+		if err != nil {
+			return nil, err
+		}
+		protoFiles = append(protoFiles, filenames...)
+	}
+
+	fileDescs, err := parseFiles(parser, protoFiles, noCache)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proto files: %w", err)
+	}
+	return fileDescs, nil
+}
+
+// checkConflicts reports symbols that are defined differently by the given
+// proto files and the included proto files. Conflicts are printed as warnings,
+// unless failOnConflict is set.
+func checkConflicts(failOnConflict bool, userSource, includeSource proto.DescriptorSource) error {
+	conflicts := proto.FindConflicts(userSource, includeSource)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	level := "Warning"
+	if failOnConflict {
+		level = "Error"
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", level, formatConflict(conflict))
+	}
+	if failOnConflict {
+		return fmt.Errorf("found %d conflicting symbol definitions", len(conflicts))
+	}
+	return nil
+}
+
+func formatConflict(conflict proto.Conflict) string {
+	origins := []string{"", " (included)"}
+	var files []string
+	for _, def := range conflict.Definitions {
+		files = append(files, def.File+origins[def.Source])
+	}
+	s := fmt.Sprintf("symbol %s is defined in %s", conflict.Name, strings.Join(files, " and "))
+	if conflict.WireCompatible {
+		return s + ", definitions are wire-compatible"
+	}
+	return s + ", definitions aren't wire-compatible: " + conflict.Reason
+}
+
+func parseFiles(parser *proto.Parser, protoFiles []string, noCache bool) ([]*desc.FileDescriptor, error) {
+	if noCache || len(protoFiles) == 0 {
+		return parser.ParseFiles(protoFiles...)
+	}
+	cacheDir, err := proto.DefaultCacheDir()
+	if err != nil {
+		return parser.ParseFiles(protoFiles...)
+	}
+	return proto.NewCache(cacheDir).ParseFiles(parser, protoFiles...)
+}
+
+// newIncludeSource loads the precompiled descriptor set of the included
+// proto files.
+func newIncludeSource() (proto.DescriptorSource, error) {
+	set, err := proto.UnmarshalDescriptorSet(protoIncludeSet)
+	if err != nil {
+		return nil, err
+	}
+	return proto.NewDescriptorSetSource(set)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/katexochen/ttrpcurl"
 	"github.com/katexochen/ttrpcurl/proto"
@@ -15,41 +16,94 @@ func newRootCmd() *cobra.Command {
 	cobra.EnableCommandSorting = false
 
 	cmd := &cobra.Command{
-		Use:   "ttrpcurl [flags] <socket> <method>",
-		Short: "Make ttrpc calls based on a proto file",
-		Args:  cobra.MatchAll(cobra.ExactArgs(2)),
-		RunE:  runRoot,
+		Use:         "ttrpcurl [flags] <socket> <method>",
+		Short:       "Make ttrpc calls based on a proto file",
+		Args:        cobra.MatchAll(cobra.ExactArgs(2)),
+		RunE:        runRoot,
+		Annotations: map[string]string{sourceAnnotation: ""},
 	}
 
 	cmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output.")
 	cmd.PersistentFlags().StringSlice("proto", []string{}, prettify(`
 		The path of a proto source file. May specify more than one via repeated
-		use of the flag or by passing a comma separated list of strings.`))
-	// Imports will be resolved using the given -import-path flags.
+		use of the flag or by passing a comma separated list of strings. If the
+		value is a directory, a .zip, .tar or .tar.gz archive or a Go module
+		reference 'module@version[/subdir]', all proto files in it are used.`))
+	cmd.PersistentFlags().StringSlice("import-path", []string{}, prettify(`
+		The path to a directory, a .zip, .tar or .tar.gz archive or a Go module
+		reference 'module@version[/subdir]' from which proto sources and their
+		imports are resolved. Modules are looked up in the local module cache,
+		files of a module are named by their import path. May specify more
+		than one via repeated use of the flag.`))
+	cmd.PersistentFlags().Bool("no-cache", false, prettify(`
+		Don't use the descriptor cache. By default, parsed proto files are cached
+		in $XDG_CACHE_HOME/ttrpcurl and reused as long as they don't change.`))
+	cmd.PersistentFlags().Bool("fail-on-conflict", false, prettify(`
+		Fail if a symbol of the given proto files is also defined by the
+		included proto files, but with a different definition. By default,
+		such conflicts are reported as warnings and the given proto files
+		take precedence.`))
 	// It is an error to use both -protoset and -proto flags.
-	if protoFlagRequired {
-		must(cmd.MarkPersistentFlagRequired("proto"))
-	}
 
 	cmd.Flags().StringP("data", "d", "", prettify(`
 		Data for request contents. If the value is '@' then the request contents
 		are read from stdin. For calls that accept a stream of requests, the
 		contents should include all such request messages concatenated together
 		(possibly delimited; see -format).`))
+	cmd.Flags().Bool("edit", false, prettify(`
+		Open $VISUAL or $EDITOR on the request before sending it. The editor
+		starts with a template of the input type, with the comments and types
+		of all fields, or with the request last edited for the method. The
+		edited request is kept in $XDG_CONFIG_HOME/ttrpcurl/requests.`))
+	cmd.Flags().StringArray("set", []string{}, prettify(`
+		Set a field of the request, given as 'path=value', on top of the
+		request contents given by -d. The path consists of field names
+		separated by dots, like 'spec.process.args[0]'. Repeated fields are
+		indexed by position, where 'args[]' appends an element, and map fields
+		by key, like 'labels[key]'. Integers are given in decimal or with a
+		'0x' prefix in hexadecimal. Enums are given by name, bytes literally or
+		as '@file', timestamps in RFC 3339 format or as 'now', durations like
+		'1m30s' and other messages in JSON. Fields are set in every request of
+		a stream, or in a single empty request without -d. May be repeated.`))
 	cmd.Flags().String("format", "json", prettify(`
-		The format of request data. The allowed values are 'json' or 'text'. For
-		'json', the input data must be in JSON format. Multiple request values
-		may be concatenated (messages with a JSON representation other than
-		object must be separated by whitespace, such as a newline). For 'text',
-		the input data must be in the protobuf text format, in which case
-		multiple request values must be separated by the "record separator"
-		ASCII character: 0x1E. The stream should not end in a record separator.
-		If it does, it will be interpreted as a final, blank message after the
+		The format of request and response data. The allowed values are 'json',
+		'yaml', 'binary' or 'text'. For 'json', the input data must be in JSON
+		format. Multiple request values may be concatenated (messages with a
+		JSON representation other than object must be separated by whitespace,
+		such as a newline). For 'yaml', the input data must be in YAML format,
+		using the same mapping as JSON. Multiple request values are the
+		documents of a multi-document YAML stream, separated by '---'. For
+		'binary', the input data must be in the protobuf wire format, with each
+		request prefixed by its size as varint, and responses are written in the
+		wire format as is, or length-delimited with --delimited. For 'text', the
+		input data must be in the protobuf text format, in which case multiple
+		request values must be separated by the "record separator" ASCII
+		character: 0x1E. The stream should not end in a record separator. If it
+		does, it will be interpreted as a final, blank message after the
 		separator.`))
-	// cmd.Flags().Bool("allow-unknown-fields", false, prettify(`
-	// 	When true, the request contents, if 'json' format is used, allows
-	// 	unknown fields to be present. They will be ignored when parsing
-	// 	the request.`))
+	cmd.Flags().Bool("delimited", false, prettify(`
+		With --format binary, prefix each response with its size as varint, so
+		the responses of streaming methods can be separated. This is the format
+		of binary requests and of 'ttrpcurl decode --delimited'.`))
+	cmd.Flags().Bool("raw", false, prettify(`
+		Call the method without proto files. The method is given as
+		'package.Service/Method', the request data is the message in wire format,
+		encoded as set by --encoding. The fields of the response are printed with
+		their number, wire type and guessed value, like protoc --decode_raw.`))
+	cmd.Flags().String("encoding", "hex", prettify(`
+		The encoding of the request data with --raw. The allowed values are 'hex'
+		or 'base64'.`))
+	cmd.Flags().Bool("annotate-unknown-fields", false, prettify(`
+		Include fields of the response that are unknown to the proto files in
+		the output. In JSON format, they are listed with their number, wire type
+		and guessed value under the '@unknownFields' key of their message.`))
+	cmd.Flags().Bool("strict-schema", false, prettify(`
+		Fail if the response contains fields that are unknown to the proto
+		files. By default, such fields are reported as warnings, as they
+		indicate that the server uses newer proto files.`))
+	cmd.Flags().Bool("allow-unknown-fields", false, prettify(`
+		When true, the request contents may contain fields that are unknown
+		to the proto files. They will be ignored when parsing the request.`))
 	// cmd.Flags().Duration("connect-timeout", 0, prettify(`
 	// 	The maximum time, in seconds, to wait for connection to be established.
 	// 	Defaults to 10 seconds.`))
@@ -66,9 +120,11 @@ func newRootCmd() *cobra.Command {
 	// cmd.Flags().Bool("emit-defaults", false, prettify(`
 	// 	Emit default values for JSON-encoded responses.`))
 
+	cmd.MarkFlagsMutuallyExclusive("edit", "data")
+	cmd.MarkFlagsMutuallyExclusive("edit", "raw")
+
 	// Unused flags, might be implemented in the future
 	// rootCmd.Flags().StringSlice("protoset", nil, "")
-	// rootCmd.Flags().StringSlice("import-path", nil, "")
 	// rootCmd.Flags().Bool("use-reflection", false, "")
 	// rootCmd.Flags().StringP("add-header", "H", "", "")
 	// rootCmd.Flags().String("rpc-header", "", "")
@@ -96,16 +152,27 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		data = []byte(flags.data)
 	}
 
-	parser := proto.NewParser()
-	fileDescs, err := parser.ParseFiles(flags.proto...)
-	if err != nil {
-		return fmt.Errorf("parsing proto files: %w", err)
+	if flags.raw {
+		return runRaw(cmd, flags, args, data)
 	}
-	includedFileDescs, err := parser.WalkAndParse(protoIncludeFS, protoIncludePath)
+
+	source, err := newSource(flags.sourceFlags)
 	if err != nil {
-		return fmt.Errorf("parsing included proto files: %w", err)
+		return err
+	}
+
+	resolver := proto.NewTypeResolver(source)
+	inputUnmarshaler := proto.Unmarshaler{
+		Format:             flags.format,
+		Resolver:           resolver,
+		AllowUnknownFields: flags.allowUnknownFields,
+	}
+	if flags.edit {
+		data, err = editRequest(source, args[1], inputUnmarshaler)
+		if err != nil {
+			return err
+		}
 	}
-	source := proto.NewSource(fileDescs, includedFileDescs)
 
 	dialer := net.Dialer{}
 	conn, err := dialer.Dial("unix", args[0])
@@ -114,18 +181,89 @@ func runRoot(cmd *cobra.Command, args []string) error {
 	}
 	defer conn.Close()
 
-	outputMarshaler := proto.Marshaler{Multiline: true}
-	client := ttrpcurl.NewClient(conn, source, outputMarshaler)
+	outputMarshaler := proto.Marshaler{
+		Multiline:             true,
+		Format:                flags.format,
+		Resolver:              resolver,
+		AnnotateUnknownFields: flags.annotateUnknownFields,
+	}
+	opts := []ttrpcurl.ClientOption{ttrpcurl.WithInputUnmarshaler(inputUnmarshaler)}
+	if flags.strictSchema {
+		opts = append(opts, ttrpcurl.WithStrictSchema())
+	}
+	if len(flags.assignments) > 0 {
+		opts = append(opts, ttrpcurl.WithAssignments(flags.assignments))
+	}
+	if flags.delimited {
+		opts = append(opts, ttrpcurl.WithDelimitedOutput())
+	}
+	client := ttrpcurl.NewClient(conn, source, outputMarshaler, opts...)
 
 	return client.Call(cmd.Context(), args[1], data)
 }
 
+// runRaw calls a method with a request in wire format and prints the raw
+// decoded response.
+func runRaw(cmd *cobra.Command, flags *rootFlags, args []string, data []byte) error {
+	service, method, ok := splitRawMethod(args[1])
+	if !ok {
+		return fmt.Errorf("method %q must be given as 'package.Service/Method'", args[1])
+	}
+	if len(flags.assignments) > 0 {
+		return fmt.Errorf("flag --set can't be used with --raw, as it requires proto files")
+	}
+	req, err := decodeEncoding(flags.encoding, data)
+	if err != nil {
+		return err
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.Dial("unix", args[0])
+	if err != nil {
+		return fmt.Errorf("dialing unix domain socket: %w", err)
+	}
+	defer conn.Close()
+
+	client := ttrpcurl.NewClient(conn, nil, proto.Marshaler{})
+	resp, err := client.CallRaw(cmd.Context(), service, method, req)
+	if err != nil {
+		return err
+	}
+	fields, err := proto.DecodeRaw(resp)
+	if err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	fmt.Print(proto.FormatRaw(fields))
+	return nil
+}
+
+// splitRawMethod splits 'package.Service/Method' or 'package.Service.Method'
+// into service and method.
+func splitRawMethod(name string) (service, method string, ok bool) {
+	name = strings.TrimPrefix(name, "/")
+	if service, method, ok := strings.Cut(name, "/"); ok {
+		return service, method, service != "" && method != ""
+	}
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], i > 0 && i < len(name)-1
+}
+
 type rootFlags struct {
-	verbose bool     // persistent
-	proto   []string // persistent
-	data    string
-	format  string
-	// allowUnknownFields bool
+	verbose               bool // persistent
+	sourceFlags                // persistent
+	data                  string
+	edit                  bool
+	assignments           []proto.Assignment
+	format                string
+	delimited             bool
+	raw                   bool
+	encoding              string
+	annotateUnknownFields bool
+	strictSchema          bool
+	allowUnknownFields    bool
 	// connectTimeout     time.Duration
 	// formatError        bool
 	// maxTime            time.Duration
@@ -141,7 +279,7 @@ func parseRootFlags(cmd *cobra.Command) (*rootFlags, error) {
 	if err != nil {
 		return nil, err
 	}
-	f.proto, err = cmd.Flags().GetStringSlice("proto")
+	f.sourceFlags, err = parseSourceFlags(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -149,14 +287,66 @@ func parseRootFlags(cmd *cobra.Command) (*rootFlags, error) {
 	if err != nil {
 		return nil, err
 	}
+	f.edit, err = cmd.Flags().GetBool("edit")
+	if err != nil {
+		return nil, err
+	}
+	sets, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		a, err := proto.ParseAssignment(set)
+		if err != nil {
+			return nil, err
+		}
+		f.assignments = append(f.assignments, a)
+	}
 	f.format, err = cmd.Flags().GetString("format")
 	if err != nil {
 		return nil, err
 	}
-	// f.allowUnknownFields, err = cmd.Flags().GetBool("allow-unknown-fields")
-	// if err != nil {
-	// 	return nil, err
-	// }
+	switch f.format {
+	case "json":
+	case "yaml":
+	case "text":
+	case "binary":
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", f.format)
+	}
+	f.delimited, err = cmd.Flags().GetBool("delimited")
+	if err != nil {
+		return nil, err
+	}
+	if f.delimited && f.format != "binary" {
+		return nil, fmt.Errorf("flag --delimited is only supported for --format=binary")
+	}
+	f.raw, err = cmd.Flags().GetBool("raw")
+	if err != nil {
+		return nil, err
+	}
+	f.encoding, err = cmd.Flags().GetString("encoding")
+	if err != nil {
+		return nil, err
+	}
+	switch f.encoding {
+	case "hex":
+	case "base64":
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %q", f.encoding)
+	}
+	f.annotateUnknownFields, err = cmd.Flags().GetBool("annotate-unknown-fields")
+	if err != nil {
+		return nil, err
+	}
+	f.strictSchema, err = cmd.Flags().GetBool("strict-schema")
+	if err != nil {
+		return nil, err
+	}
+	f.allowUnknownFields, err = cmd.Flags().GetBool("allow-unknown-fields")
+	if err != nil {
+		return nil, err
+	}
 	// f.connectTimeout, err = cmd.Flags().GetDuration("connect-timeout")
 	// if err != nil {
 	// 	return nil, err
@@ -10,6 +10,7 @@ require (
 	github.com/spf13/cobra v1.7.0
 	google.golang.org/grpc v1.57.0
 	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,36 @@
+// protosetgen compiles all proto files of a directory into a binary
+// FileDescriptorSet, so they can be embedded without parsing them at runtime.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/katexochen/ttrpcurl/proto"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory containing the proto files")
+	out := flag.String("out", "", "path of the descriptor set to write")
+	flag.Parse()
+
+	if *out == "" {
+		log.Fatal("flag -out is required")
+	}
+
+	files, err := proto.NewParser().WalkAndParse(os.DirFS(*dir), ".")
+	if err != nil {
+		log.Fatalf("parsing proto files: %v", err)
+	}
+	if len(files) == 0 {
+		log.Printf("no proto files in %s, writing an empty descriptor set", *dir)
+	}
+	b, err := proto.MarshalDescriptorSet(files...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatalf("writing descriptor set: %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Payloads of google.protobuf.Any messages that can't be resolved can't be
+// encoded by protojson. Before encoding, such payloads are replaced by a
+// well-known type with a placeholder type URL, which is replaced by the
+// original type URL in the encoded JSON afterwards:
+//
+//   - JSON payloads, as used by containerd's typeurl package for types that
+//     aren't protobuf messages, become a google.protobuf.Value.
+//   - All other payloads become a google.protobuf.BytesValue.
+const anyPlaceholderPrefix = "ttrpcurl.invalid/placeholder/"
+
+// containerdTypeURLPrefix is the prefix containerd's typeurl package uses for
+// registered types. Types that aren't protobuf messages are registered under
+// a path below this prefix and encoded as JSON.
+const containerdTypeURLPrefix = "types.containerd.io/"
+
+// isContainerdJSONTypeURL reports whether url is the type URL of a type
+// registered with containerd's typeurl package that is encoded as JSON, like
+// types.containerd.io/opencontainers/runtime-spec/1/Spec.
+func isContainerdJSONTypeURL(url string) bool {
+	path, ok := strings.CutPrefix(url, containerdTypeURLPrefix)
+	return ok && strings.Contains(path, "/")
+}
+
+type anyField struct {
+	typeURL protoreflect.FieldDescriptor
+	value   protoreflect.FieldDescriptor
+}
+
+func anyFields(m protoreflect.Message) (anyField, bool) {
+	md := m.Descriptor()
+	if md.FullName() != "google.protobuf.Any" {
+		return anyField{}, false
+	}
+	return anyField{
+		typeURL: md.Fields().ByName("type_url"),
+		value:   md.Fields().ByName("value"),
+	}, true
+}
+
+// rangeAnys calls fn for every google.protobuf.Any message in m.
+func rangeAnys(m protoreflect.Message, fn func(a protoreflect.Message, fields anyField) error) error {
+	if fields, ok := anyFields(m); ok {
+		return fn(m, fields)
+	}
+
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = rangeAnys(list.Get(i).Message(), fn)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				err = rangeAnys(v.Message(), fn)
+				return err == nil
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			err = rangeAnys(v.Message(), fn)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// anyEncoder replaces unresolvable Any payloads by placeholders before a
+// message is encoded as JSON.
+type anyEncoder struct {
+	resolver Resolver
+	// typeURLs are the original type URLs, indexed by placeholder number.
+	typeURLs []string
+}
+
+// replace replaces all unresolvable Any payloads in m, including the ones
+// nested in resolvable Any payloads. It reports whether m was changed.
+func (e *anyEncoder) replace(m protoreflect.Message) (bool, error) {
+	changed := false
+	err := rangeAnys(m, func(a protoreflect.Message, fields anyField) error {
+		typeURL := a.Get(fields.typeURL).String()
+		value := a.Get(fields.value).Bytes()
+
+		if mt, err := e.resolver.FindMessageByURL(typeURL); err == nil {
+			payload := mt.New()
+			if err := (proto.UnmarshalOptions{Resolver: e.resolver}).Unmarshal(value, payload.Interface()); err != nil {
+				// Let protojson report the error.
+				return nil
+			}
+			payloadChanged, err := e.replace(payload)
+			if err != nil || !payloadChanged {
+				return err
+			}
+			b, err := proto.Marshal(payload.Interface())
+			if err != nil {
+				return err
+			}
+			a.Set(fields.value, protoreflect.ValueOfBytes(b))
+			changed = true
+			return nil
+		}
+
+		var placeholder proto.Message = wrapperspb.Bytes(value)
+		if json.Valid(value) {
+			jsonValue := &structpb.Value{}
+			if err := protojson.Unmarshal(value, jsonValue); err == nil {
+				placeholder = jsonValue
+			}
+		}
+		b, err := proto.Marshal(placeholder)
+		if err != nil {
+			return err
+		}
+		placeholderURL := fmt.Sprintf("%s%d/%s", anyPlaceholderPrefix, len(e.typeURLs), placeholder.ProtoReflect().Descriptor().FullName())
+		e.typeURLs = append(e.typeURLs, typeURL)
+		a.Set(fields.typeURL, protoreflect.ValueOfString(placeholderURL))
+		a.Set(fields.value, protoreflect.ValueOfBytes(b))
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// restore replaces the placeholders in the decoded JSON value v by the
+// original type URLs. JSON objects are inlined next to the type URL, like
+// protojson does for resolvable messages.
+func (e *anyEncoder) restore(v any) any {
+	switch v := v.(type) {
+	case *jsonObject:
+		for _, key := range v.keys {
+			v.values[key] = e.restore(v.values[key])
+		}
+		i, ok := placeholderIndex(v.values["@type"])
+		if !ok || i >= len(e.typeURLs) {
+			return v
+		}
+		isJSON := strings.HasSuffix(v.values["@type"].(string), "/google.protobuf.Value")
+		v.set("@type", e.typeURLs[i])
+		if payload, ok := v.values["value"].(*jsonObject); ok && isJSON {
+			v.delete("value")
+			for _, key := range payload.keys {
+				if key != "@type" {
+					v.set(key, payload.values[key])
+				}
+			}
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = e.restore(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// anyDecoder replaces containerd JSON payloads by placeholders before a message
+// is decoded from JSON, and restores them afterwards.
+type anyDecoder struct {
+	resolver Resolver
+	typeURLs []string
+}
+
+// replace replaces all JSON objects with a containerd JSON type URL in the
+// decoded JSON value v by placeholders.
+func (d *anyDecoder) replace(v any) any {
+	switch v := v.(type) {
+	case *jsonObject:
+		for _, key := range v.keys {
+			v.values[key] = d.replace(v.values[key])
+		}
+		typeURL, ok := v.values["@type"].(string)
+		if !ok || !isContainerdJSONTypeURL(typeURL) {
+			return v
+		}
+		if _, err := d.resolver.FindMessageByURL(typeURL); err == nil {
+			return v
+		}
+		v.delete("@type")
+		var payload any = v
+		if value, ok := v.values["value"]; ok && len(v.keys) == 1 {
+			if _, isObject := value.(*jsonObject); !isObject {
+				payload = value
+			}
+		}
+		placeholderURL := fmt.Sprintf("%s%d/google.protobuf.Value", anyPlaceholderPrefix, len(d.typeURLs))
+		d.typeURLs = append(d.typeURLs, typeURL)
+		placeholder := newJSONObject()
+		placeholder.set("@type", placeholderURL)
+		placeholder.set("value", payload)
+		return placeholder
+	case []any:
+		for i, value := range v {
+			v[i] = d.replace(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// restore replaces the placeholders in m by the original type URLs and the
+// JSON encoded payload, including the ones nested in other Any payloads. It
+// reports whether m was changed.
+func (d *anyDecoder) restore(m protoreflect.Message) (bool, error) {
+	changed := false
+	err := rangeAnys(m, func(a protoreflect.Message, fields anyField) error {
+		i, ok := placeholderIndex(a.Get(fields.typeURL).String())
+		if !ok || i >= len(d.typeURLs) {
+			payloadChanged, err := d.restorePayload(a, fields)
+			changed = changed || payloadChanged
+			return err
+		}
+
+		jsonValue := &structpb.Value{}
+		if err := proto.Unmarshal(a.Get(fields.value).Bytes(), jsonValue); err != nil {
+			return err
+		}
+		b, err := protojson.Marshal(jsonValue)
+		if err != nil {
+			return err
+		}
+		a.Set(fields.typeURL, protoreflect.ValueOfString(d.typeURLs[i]))
+		a.Set(fields.value, protoreflect.ValueOfBytes(b))
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+func (d *anyDecoder) restorePayload(a protoreflect.Message, fields anyField) (bool, error) {
+	mt, err := d.resolver.FindMessageByURL(a.Get(fields.typeURL).String())
+	if err != nil {
+		return false, nil
+	}
+	payload := mt.New()
+	if err := (proto.UnmarshalOptions{Resolver: d.resolver}).Unmarshal(a.Get(fields.value).Bytes(), payload.Interface()); err != nil {
+		return false, err
+	}
+	changed, err := d.restore(payload)
+	if err != nil || !changed {
+		return false, err
+	}
+	b, err := proto.Marshal(payload.Interface())
+	if err != nil {
+		return false, err
+	}
+	a.Set(fields.value, protoreflect.ValueOfBytes(b))
+	return true, nil
+}
+
+// marshalJSONWithAnys encodes mes as JSON, replacing unresolvable Any payloads
+// by readable representations.
+func marshalJSONWithAnys(opts protojson.MarshalOptions, resolver Resolver, mes protoreflect.ProtoMessage) ([]byte, error) {
+	mes = proto.Clone(mes)
+	enc := &anyEncoder{resolver: resolver}
+	changed, err := enc.replace(mes.ProtoReflect())
+	if err != nil {
+		return nil, err
+	}
+	b, err := opts.Marshal(mes)
+	if err != nil || !changed {
+		return b, err
+	}
+
+	intermed, err := decodeOrderedJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return marshalOrderedJSON(enc.restore(intermed))
+}
+
+// unmarshalJSONWithAnys decodes mes from JSON, accepting Any messages with
+// containerd JSON type URLs.
+func unmarshalJSONWithAnys(opts protojson.UnmarshalOptions, resolver Resolver, b []byte, mes protoreflect.ProtoMessage) error {
+	if !bytes.Contains(b, []byte(containerdTypeURLPrefix)) {
+		return opts.Unmarshal(b, mes)
+	}
+
+	intermed, err := decodeOrderedJSON(b)
+	if err != nil {
+		return err
+	}
+	dec := &anyDecoder{resolver: resolver}
+	intermed = dec.replace(intermed)
+	if len(dec.typeURLs) == 0 {
+		return opts.Unmarshal(b, mes)
+	}
+	b, err = marshalOrderedJSON(intermed)
+	if err != nil {
+		return err
+	}
+	if err := opts.Unmarshal(b, mes); err != nil {
+		return err
+	}
+	_, err = dec.restore(mes.ProtoReflect())
+	return err
+}
+
+func decodeJSON(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func placeholderIndex(typeURL any) (int, bool) {
+	s, ok := typeURL.(string)
+	if !ok {
+		return 0, false
+	}
+	rest, ok := strings.CutPrefix(s, anyPlaceholderPrefix)
+	if !ok {
+		return 0, false
+	}
+	index, _, _ := strings.Cut(rest, "/")
+	i, err := strconv.Atoi(index)
+	return i, err == nil
+}
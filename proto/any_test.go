@@ -0,0 +1,40 @@
+package proto
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMarshalJSONWithAnysKeepsOrder(t *testing.T) {
+	src := NewSource(mustFSSource(t, `
+import "google/protobuf/any.proto";
+message Holder {
+  string z = 1;
+  google.protobuf.Any spec = 2;
+  string a = 3;
+}
+`))
+	md, err := src.FindMessage("test.Holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mes := dynamicpb.NewMessage(md.UnwrapMessage())
+	fields := mes.Descriptor().Fields()
+	mes.Set(fields.ByName("z"), protoreflect.ValueOfString("<z>"))
+	spec := &anypb.Any{TypeUrl: "types.containerd.io/opencontainers/runtime-spec/1/Spec", Value: []byte(`{"ociVersion":"1.0.2"}`)}
+	mes.Set(fields.ByName("spec"), protoreflect.ValueOfMessage(spec.ProtoReflect()))
+	mes.Set(fields.ByName("a"), protoreflect.ValueOfString("&a"))
+
+	got, err := marshalJSONWithAnys(protojson.MarshalOptions{}, NewTypeResolver(src), mes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"z":"<z>","spec":{"@type":"types.containerd.io/opencontainers/runtime-spec/1/Spec","ociVersion":"1.0.2"},"a":"&a"}`
+	if string(got) != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
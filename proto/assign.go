@@ -0,0 +1,359 @@
+package proto
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Assignment sets the value of a field of a message, given on the command
+// line as 'path=value'. The path consists of field names separated by dots,
+// where repeated fields are indexed like 'args[0]' and map fields are keyed
+// like 'labels[key]' or 'labels["a.b"]'. Indexing a repeated field with its
+// length or with an empty index, like 'args[]', appends an element.
+//
+// Values are parsed according to the type of the field. Integers are given
+// in decimal, or in hexadecimal with a '0x' prefix. Enums are given by
+// name or number, bytes are given literally or as '@file' to read them from
+// a file. Timestamps are given in RFC 3339 format or as 'now', durations like
+// '1m30s'. Other messages are given in their JSON representation.
+type Assignment struct {
+	Path  string
+	Value string
+}
+
+// ParseAssignment parses an assignment of the form 'path=value'.
+func ParseAssignment(s string) (Assignment, error) {
+	// Map keys may contain '=', so the first '=' outside of brackets
+	// separates path and value. Quoted keys may also contain brackets, so
+	// they are skipped like in parseIndex.
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+			if strings.HasPrefix(s[i+1:], `"`) {
+				if quoted, err := strconv.QuotedPrefix(s[i+1:]); err == nil {
+					i += len(quoted)
+				}
+			}
+		case ']':
+			depth--
+		case '=':
+			if depth == 0 && i > 0 {
+				return Assignment{Path: s[:i], Value: s[i+1:]}, nil
+			}
+		}
+	}
+	return Assignment{}, fmt.Errorf("invalid assignment %q, expected path=value", s)
+}
+
+// Apply sets the field of m at the assignment's path. Messages given in
+// JSON are unmarshaled with the resolver of u, regardless of its format.
+func (a Assignment) Apply(m protoreflect.Message, u Unmarshaler) error {
+	u.Format = "json"
+	segments, err := parseFieldPath(a.Path)
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", a.Path, err)
+	}
+	if err := assign(m, segments, a.Value, "", u); err != nil {
+		return fmt.Errorf("setting %s: %w", a.Path, err)
+	}
+	return nil
+}
+
+// pathSegment is a field name, optionally followed by an index or map key.
+type pathSegment struct {
+	name     string
+	index    string
+	hasIndex bool
+}
+
+func parseFieldPath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for rest := path; ; {
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		seg := pathSegment{name: rest[:end]}
+		if seg.name == "" {
+			return nil, fmt.Errorf("invalid path %q: empty field name", path)
+		}
+		rest = rest[end:]
+
+		if strings.HasPrefix(rest, "[") {
+			index, n, err := parseIndex(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", path, err)
+			}
+			seg.index, seg.hasIndex = index, true
+			rest = rest[n:]
+		}
+		segments = append(segments, seg)
+
+		if rest == "" {
+			return segments, nil
+		}
+		if !strings.HasPrefix(rest, ".") {
+			return nil, fmt.Errorf("invalid path %q: expected '.' before %q", path, rest)
+		}
+		rest = rest[1:]
+	}
+}
+
+// parseIndex parses the index in brackets at the start of s, which may be a
+// quoted string, and returns it with the number of consumed bytes.
+func parseIndex(s string) (string, int, error) {
+	if strings.HasPrefix(s, `["`) {
+		quoted, err := strconv.QuotedPrefix(s[1:])
+		if err != nil || !strings.HasPrefix(s[1+len(quoted):], "]") {
+			return "", 0, fmt.Errorf("unterminated index %s", s)
+		}
+		index, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", 0, err
+		}
+		return index, len(quoted) + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated index %s", s)
+	}
+	return s[1:end], end + 1, nil
+}
+
+func assign(m protoreflect.Message, segments []pathSegment, value, path string, u Unmarshaler) error {
+	seg := segments[0]
+	md := m.Descriptor()
+	fd := md.Fields().ByJSONName(seg.name)
+	if fd == nil {
+		fd = md.Fields().ByName(protoreflect.Name(seg.name))
+	}
+	if fd == nil {
+		return unknownFieldError(md, seg.name, path)
+	}
+	path = joinJSONPath(path, seg.name)
+	last := len(segments) == 1
+
+	switch {
+	case fd.IsMap():
+		if !seg.hasIndex {
+			return fmt.Errorf("map field %s needs a key, like %s[key]", path, path)
+		}
+		key, err := parseScalar(fd.MapKey(), seg.index, "key of "+path)
+		if err != nil {
+			return err
+		}
+		mp := m.Mutable(fd).Map()
+		if last {
+			v, err := parseValue(fd.MapValue(), value, mp.NewValue(), u, fmt.Sprintf("%s[%q]", path, seg.index))
+			if err != nil {
+				return err
+			}
+			mp.Set(key.MapKey(), v)
+			return nil
+		}
+		if fd.MapValue().Message() == nil {
+			return fmt.Errorf("values of %s aren't messages", path)
+		}
+		return assign(mp.Mutable(key.MapKey()).Message(), segments[1:], value, fmt.Sprintf("%s[%q]", path, seg.index), u)
+
+	case fd.IsList():
+		list := m.Mutable(fd).List()
+		index := list.Len()
+		if seg.hasIndex && seg.index != "" {
+			i, err := strconv.Atoi(seg.index)
+			if err != nil || i < 0 {
+				return fmt.Errorf("invalid index of %s: %q", path, seg.index)
+			}
+			if i > list.Len() {
+				return fmt.Errorf("index %d of %s is out of range, the list has %d elements", i, path, list.Len())
+			}
+			index = i
+		} else if !seg.hasIndex && !last {
+			return fmt.Errorf("repeated field %s needs an index, like %s[0]", path, path)
+		}
+		elemPath := fmt.Sprintf("%s[%d]", path, index)
+
+		if last {
+			v, err := parseValue(fd, value, list.NewElement(), u, elemPath)
+			if err != nil {
+				return err
+			}
+			if index == list.Len() {
+				list.Append(v)
+			} else {
+				list.Set(index, v)
+			}
+			return nil
+		}
+		if fd.Message() == nil {
+			return fmt.Errorf("elements of %s aren't messages", path)
+		}
+		if index == list.Len() {
+			return assign(list.AppendMutable().Message(), segments[1:], value, elemPath, u)
+		}
+		return assign(list.Get(index).Message(), segments[1:], value, elemPath, u)
+
+	default:
+		if seg.hasIndex {
+			return fmt.Errorf("field %s isn't repeated or a map", path)
+		}
+		if last {
+			v, err := parseValue(fd, value, m.NewField(fd), u, path)
+			if err != nil {
+				return err
+			}
+			m.Set(fd, v)
+			return nil
+		}
+		if fd.Message() == nil {
+			return fmt.Errorf("field %s isn't a message", path)
+		}
+		return assign(m.Mutable(fd).Message(), segments[1:], value, path, u)
+	}
+}
+
+// parseValue parses s as value of the field fd at path. For messages, s is
+// parsed into the message of zero.
+func parseValue(fd protoreflect.FieldDescriptor, s string, zero protoreflect.Value, u Unmarshaler, path string) (protoreflect.Value, error) {
+	if fd.Message() == nil {
+		return parseScalar(fd, s, path)
+	}
+
+	m := zero.Message()
+	switch md := fd.Message(); md.FullName() {
+	case "google.protobuf.Timestamp":
+		t := time.Now()
+		if s != "now" {
+			var err error
+			if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return protoreflect.Value{}, invalidValueError(path, "timestamp like 2006-01-02T15:04:05Z or now", s)
+			}
+		}
+		m.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+		m.Set(md.Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+	case "google.protobuf.Duration":
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueError(path, "duration like 1m30s", s)
+		}
+		m.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(int64(d/time.Second)))
+		m.Set(md.Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(d%time.Second)))
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue",
+		"google.protobuf.BytesValue":
+		valueField := md.Fields().ByName("value")
+		v, err := parseScalar(valueField, s, path)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		m.Set(valueField, v)
+	default:
+		if err := u.Unmarshal([]byte(s), m.Interface()); err != nil {
+			return protoreflect.Value{}, fmt.Errorf("invalid value for %s: %w", path, err)
+		}
+	}
+	return zero, nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s, path string) (protoreflect.Value, error) {
+	var err error
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		if name, ok := strings.CutPrefix(s, "@"); ok {
+			b, err := os.ReadFile(name)
+			if err != nil {
+				return protoreflect.Value{}, fmt.Errorf("reading value for %s: %w", path, err)
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.EnumKind:
+		return parseEnum(fd.Enum(), s, path)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var i int64
+		if i, err = parseInt(s, 32); err == nil {
+			return protoreflect.ValueOfInt32(int32(i)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var i int64
+		if i, err = parseInt(s, 64); err == nil {
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var i uint64
+		if i, err = parseUint(s, 32); err == nil {
+			return protoreflect.ValueOfUint32(uint32(i)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var i uint64
+		if i, err = parseUint(s, 64); err == nil {
+			return protoreflect.ValueOfUint64(i), nil
+		}
+	case protoreflect.FloatKind:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 32); err == nil {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 64); err == nil {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	}
+	return protoreflect.Value{}, invalidValueError(path, jsonTypeName(fd), s)
+}
+
+// parseInt parses a decimal integer, or a hexadecimal one with a '0x'
+// prefix. Unlike with base 0, leading zeros don't select octal and
+// underscores aren't accepted, like in JSON.
+func parseInt(s string, bitSize int) (int64, error) {
+	sign, digits := "", s
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, digits = "-", rest
+	}
+	if hex, ok := cutHexPrefix(digits); ok {
+		return strconv.ParseInt(sign+hex, 16, bitSize)
+	}
+	return strconv.ParseInt(s, 10, bitSize)
+}
+
+// parseUint is like parseInt for unsigned integers.
+func parseUint(s string, bitSize int) (uint64, error) {
+	if hex, ok := cutHexPrefix(s); ok {
+		return strconv.ParseUint(hex, 16, bitSize)
+	}
+	return strconv.ParseUint(s, 10, bitSize)
+}
+
+func cutHexPrefix(s string) (string, bool) {
+	if hex, ok := strings.CutPrefix(s, "0x"); ok {
+		return hex, true
+	}
+	return strings.CutPrefix(s, "0X")
+}
+
+func parseEnum(ed protoreflect.EnumDescriptor, s, path string) (protoreflect.Value, error) {
+	if v := ed.Values().ByName(protoreflect.Name(s)); v != nil {
+		return protoreflect.ValueOfEnum(v.Number()), nil
+	}
+	if i, err := parseInt(s, 32); err == nil {
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	}
+	return protoreflect.Value{}, checkEnum(ed, s, path)
+}
@@ -0,0 +1,160 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestAssignmentApply(t *testing.T) {
+	src := mustFSSource(t, `
+import "google/protobuf/duration.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+enum Kind { UNKNOWN = 0; BIND = 1; }
+message Mount { Kind kind = 1; string source = 2; }
+message Process { repeated string args = 1; map<string, string> env = 2; }
+message Spec {
+	Process process = 1;
+	repeated Mount mounts = 2;
+	map<string, Mount> named = 3;
+	bytes data = 4;
+	google.protobuf.Timestamp created = 5;
+	google.protobuf.Duration timeout = 6;
+	google.protobuf.StringValue note = 7;
+	uint32 uid = 8;
+}
+message Request { Spec spec = 1; }`)
+	md, err := NewSource(src).FindMessage("test.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := Unmarshaler{Format: "json", Resolver: NewTypeResolver(src)}
+
+	file := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(file, []byte("from file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		base        string
+		assignments []string
+		want        string
+		wantErr     bool
+	}{
+		"nested field": {
+			assignments: []string{"spec.process.args[0]=sh"},
+			want:        `{"spec":{"process":{"args":["sh"]}}}`,
+		},
+		"decimal with leading zero": {
+			assignments: []string{"spec.uid=010"},
+			want:        `{"spec":{"uid":10}}`,
+		},
+		"proto name": {
+			assignments: []string{"spec.uid=0x10"},
+			want:        `{"spec":{"uid":16}}`,
+		},
+		"on top of base": {
+			base:        `{"spec":{"process":{"args":["sh","-c"]},"uid":1}}`,
+			assignments: []string{"spec.process.args[1]=-x", "spec.process.args[]=true"},
+			want:        `{"spec":{"process":{"args":["sh","-x","true"]},"uid":1}}`,
+		},
+		"append message": {
+			base:        `{"spec":{"mounts":[{"source":"/a"}]}}`,
+			assignments: []string{"spec.mounts[1].kind=BIND", "spec.mounts[1].source=/b"},
+			want:        `{"spec":{"mounts":[{"source":"/a"},{"kind":"BIND","source":"/b"}]}}`,
+		},
+		"map keys": {
+			assignments: []string{`spec.process.env[PATH]=/bin`, `spec.named["a.b"].kind=1`},
+			want:        `{"spec":{"process":{"env":{"PATH":"/bin"}},"named":{"a.b":{"kind":"BIND"}}}}`,
+		},
+		"map key with equal sign": {
+			assignments: []string{`spec.process.env[a=b]=c`},
+			want:        `{"spec":{"process":{"env":{"a=b":"c"}}}}`,
+		},
+		"quoted map key with bracket": {
+			assignments: []string{`spec.process.env["a]b"]=c`, `spec.process.env["x[=y"]=z`},
+			want:        `{"spec":{"process":{"env":{"a]b":"c","x[=y":"z"}}}}`,
+		},
+		"bytes": {
+			assignments: []string{"spec.data=@" + file},
+			want:        `{"spec":{"data":"ZnJvbSBmaWxl"}}`,
+		},
+		"well-known types": {
+			assignments: []string{"spec.created=2023-01-02T03:04:05.5Z", "spec.timeout=1m30s", "spec.note=hi"},
+			want:        `{"spec":{"created":"2023-01-02T03:04:05.500Z","timeout":"90s","note":"hi"}}`,
+		},
+		"message in JSON": {
+			assignments: []string{`spec.mounts[]={"kind":"BIND"}`},
+			want:        `{"spec":{"mounts":[{"kind":"BIND"}]}}`,
+		},
+		"unknown field": {
+			assignments: []string{"spec.proces.args[0]=sh"},
+			wantErr:     true,
+		},
+		"unknown enum value": {
+			assignments: []string{"spec.mounts[0].kind=BUND"},
+			wantErr:     true,
+		},
+		"index out of range": {
+			assignments: []string{"spec.process.args[1]=sh"},
+			wantErr:     true,
+		},
+		"index on singular field": {
+			assignments: []string{"spec.uid[0]=1"},
+			wantErr:     true,
+		},
+		"invalid number": {
+			assignments: []string{"spec.uid=-1"},
+			wantErr:     true,
+		},
+		"underscores in number": {
+			assignments: []string{"spec.uid=1_000"},
+			wantErr:     true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := dynamicpb.NewMessage(md.UnwrapMessage())
+			if tc.base != "" {
+				if err := u.Unmarshal([]byte(tc.base), got); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var err error
+			for _, s := range tc.assignments {
+				var a Assignment
+				if a, err = ParseAssignment(s); err != nil {
+					break
+				}
+				if err = a.Apply(got, u); err != nil {
+					break
+				}
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := dynamicpb.NewMessage(md.UnwrapMessage())
+			if err := u.Unmarshal([]byte(tc.want), want); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, want) {
+				b, _ := Marshaler{Format: "json"}.Marshal(got)
+				t.Errorf("got %s, want %s", b, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package proto
+
+import (
+	"fmt"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// ChangeList is a list of changes between two versions of proto files.
+type ChangeList struct {
+	Changes []Change `json:"changes" yaml:"changes"`
+}
+
+// Change is a change of a symbol between two versions of proto files. Kind
+// is one of the Change* constants. Breaking changes break the wire
+// compatibility with clients or servers that use the old version, other
+// changes only break the JSON representation or generated code.
+type Change struct {
+	Kind     string `json:"kind" yaml:"kind"`
+	Symbol   string `json:"symbol" yaml:"symbol"`
+	Breaking bool   `json:"breaking" yaml:"breaking"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+const (
+	ChangeServiceRemoved         = "SERVICE_REMOVED"
+	ChangeMethodRemoved          = "METHOD_REMOVED"
+	ChangeMethodTypeChanged      = "METHOD_TYPE_CHANGED"
+	ChangeMethodStreamingChanged = "METHOD_STREAMING_CHANGED"
+	ChangeMessageRemoved         = "MESSAGE_REMOVED"
+	ChangeFieldRemoved           = "FIELD_REMOVED"
+	ChangeFieldNumberChanged     = "FIELD_NUMBER_CHANGED"
+	ChangeFieldTypeChanged       = "FIELD_TYPE_CHANGED"
+	ChangeFieldRenamed           = "FIELD_RENAMED"
+	ChangeEnumRemoved            = "ENUM_REMOVED"
+	ChangeEnumValueRemoved       = "ENUM_VALUE_REMOVED"
+	ChangeEnumValueNumberChanged = "ENUM_VALUE_NUMBER_CHANGED"
+)
+
+// FindChanges compares the services, methods, messages and enums of the old
+// and new version of proto files and returns the changes, ordered by symbol.
+// Only symbols declared in the root files of the old version are compared,
+// not the ones of imported files and well-known types. Additions aren't
+// reported, as they don't affect existing clients. Removed messages and
+// enums only break the wire format if a method or field that still exists
+// used them.
+func FindChanges(oldSrc, newSrc DescriptorSource) ChangeList {
+	newSymbols := Symbols(newSrc.Files())
+	oldGraph := NewReferenceGraph(oldSrc.Files())
+	list := ChangeList{Changes: []Change{}}
+	report := func(kind, symbol string, breaking bool, format string, args ...any) {
+		list.Changes = append(list.Changes, Change{
+			Kind:     kind,
+			Symbol:   symbol,
+			Breaking: breaking,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, oldSymbol := range sortedSymbols(Symbols(comparedFiles(oldSrc))) {
+		name := oldSymbol.GetFullyQualifiedName()
+		newSymbol := newSymbols[name]
+
+		switch old := oldSymbol.(type) {
+		case *desc.ServiceDescriptor:
+			if _, ok := newSymbol.(*desc.ServiceDescriptor); !ok {
+				report(ChangeServiceRemoved, name, true, "service %s was removed", name)
+			}
+		case *desc.MethodDescriptor:
+			if _, ok := newSymbols[old.GetService().GetFullyQualifiedName()].(*desc.ServiceDescriptor); !ok {
+				continue // Reported with the service.
+			}
+			updated, ok := newSymbol.(*desc.MethodDescriptor)
+			if !ok {
+				report(ChangeMethodRemoved, name, true, "method %s was removed", name)
+				continue
+			}
+			if old.GetInputType().GetFullyQualifiedName() != updated.GetInputType().GetFullyQualifiedName() {
+				report(ChangeMethodTypeChanged, name, true, "input type changed from %s to %s",
+					old.GetInputType().GetFullyQualifiedName(), updated.GetInputType().GetFullyQualifiedName())
+			}
+			if old.GetOutputType().GetFullyQualifiedName() != updated.GetOutputType().GetFullyQualifiedName() {
+				report(ChangeMethodTypeChanged, name, true, "output type changed from %s to %s",
+					old.GetOutputType().GetFullyQualifiedName(), updated.GetOutputType().GetFullyQualifiedName())
+			}
+			oldStreaming, newStreaming := StreamingKind(old), StreamingKind(updated)
+			if oldStreaming != newStreaming {
+				report(ChangeMethodStreamingChanged, name, true, "streaming changed from %s to %s", oldStreaming, newStreaming)
+			}
+		case *desc.MessageDescriptor:
+			if old.IsMapEntry() || parentRemoved(old, newSymbols) {
+				continue
+			}
+			updated, ok := newSymbol.(*desc.MessageDescriptor)
+			if !ok {
+				report(ChangeMessageRemoved, name, oldGraph.usedByRemaining(name, newSymbols), "message %s was removed", name)
+				continue
+			}
+			for _, oldField := range old.GetFields() {
+				fieldName := oldField.GetFullyQualifiedName()
+				newField := updated.FindFieldByNumber(oldField.GetNumber())
+				if newField == nil {
+					if renumbered := updated.FindFieldByName(oldField.GetName()); renumbered != nil {
+						report(ChangeFieldNumberChanged, fieldName, true, "field number changed from %d to %d",
+							oldField.GetNumber(), renumbered.GetNumber())
+					} else {
+						report(ChangeFieldRemoved, fieldName, true, "field %d was removed", oldField.GetNumber())
+					}
+					continue
+				}
+				if reason := fieldIncompatibility(oldField, newField); reason != "" {
+					report(ChangeFieldTypeChanged, fieldName, true, "field %d changed incompatibly: %s", oldField.GetNumber(), reason)
+				} else if FieldTypeName(oldField) != FieldTypeName(newField) {
+					report(ChangeFieldTypeChanged, fieldName, false, "field %d changed type from %s to %s",
+						oldField.GetNumber(), FieldTypeName(oldField), FieldTypeName(newField))
+				}
+				if oldField.GetName() != newField.GetName() {
+					report(ChangeFieldRenamed, fieldName, false, "field %d was renamed to %s", oldField.GetNumber(), newField.GetName())
+				}
+			}
+		case *desc.EnumDescriptor:
+			if parentRemoved(old, newSymbols) {
+				continue
+			}
+			updated, ok := newSymbol.(*desc.EnumDescriptor)
+			if !ok {
+				report(ChangeEnumRemoved, name, oldGraph.usedByRemaining(name, newSymbols), "enum %s was removed", name)
+				continue
+			}
+			for _, oldValue := range old.GetValues() {
+				if updated.FindValueByNumber(oldValue.GetNumber()) != nil {
+					continue
+				}
+				if renumbered := updated.FindValueByName(oldValue.GetName()); renumbered != nil {
+					report(ChangeEnumValueNumberChanged, oldValue.GetFullyQualifiedName(), true,
+						"enum value number changed from %d to %d", oldValue.GetNumber(), renumbered.GetNumber())
+					continue
+				}
+				report(ChangeEnumValueRemoved, oldValue.GetFullyQualifiedName(), true,
+					"enum value %d was removed", oldValue.GetNumber())
+			}
+		}
+	}
+	return list
+}
+
+// parentRemoved reports whether d is nested in a message that was removed,
+// directly or through other messages. Only the outermost removed message is
+// reported.
+func parentRemoved(d desc.Descriptor, newSymbols map[string]desc.Descriptor) bool {
+	for parent, ok := d.GetParent().(*desc.MessageDescriptor); ok; parent, ok = parent.GetParent().(*desc.MessageDescriptor) {
+		if _, ok := newSymbols[parent.GetFullyQualifiedName()].(*desc.MessageDescriptor); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Breaking returns the number of breaking changes.
+func (l ChangeList) Breaking() int {
+	var n int
+	for _, change := range l.Changes {
+		if change.Breaking {
+			n++
+		}
+	}
+	return n
+}
+
+// comparedFiles returns the root files of src, without well-known types.
+func comparedFiles(src DescriptorSource) []*desc.FileDescriptor {
+	var files []*desc.FileDescriptor
+	for _, file := range src.RootFiles() {
+		if !IsWellKnownType(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// usedByRemaining reports whether a method or field that uses the type with
+// the given name directly still exists in newSymbols.
+func (g *ReferenceGraph) usedByRemaining(name string, newSymbols map[string]desc.Descriptor) bool {
+	for _, user := range g.users[name] {
+		if _, ok := newSymbols[user.GetFullyQualifiedName()]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package proto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// cacheFormatVersion must be increased whenever the layout of cache entries
+// changes, so that old entries are no longer used.
+const cacheFormatVersion = "2"
+
+// Cache stores linked descriptor sets on disk, so that proto files which
+// haven't changed don't need to be parsed again.
+//
+// An entry is keyed by the names and contents of the parsed files and the
+// import paths added to the parser. The contents of all imported files,
+// including the well-known types provided by the parser itself, are stored in
+// a manifest next to the entry and checked when the entry is loaded.
+type Cache struct {
+	dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// DefaultCacheDir returns the directory ttrpcurl uses for caching, which is
+// $XDG_CACHE_HOME/ttrpcurl on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ttrpcurl"), nil
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Clear removes all entries from the cache.
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("removing cache directory: %w", err)
+	}
+	return nil
+}
+
+// ParseFiles parses the given files like Parser.ParseFiles does, but loads the
+// result from the cache if nothing has changed since it was stored. Failures
+// to read or write the cache are not reported, the files are parsed instead.
+func (c *Cache) ParseFiles(parser *Parser, filenames ...string) ([]*desc.FileDescriptor, error) {
+	key, err := c.key(parser, filenames)
+	if err != nil {
+		// Let the parser report missing files.
+		return parser.ParseFiles(filenames...)
+	}
+
+	if files, err := c.load(parser, key); err == nil {
+		return files, nil
+	}
+
+	files, err := parser.ParseFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.store(parser, key, files)
+	return files, nil
+}
+
+type cacheManifest struct {
+	// Roots are the names of the parsed files, in the order they were given.
+	Roots []string `json:"roots"`
+	// Sums are the SHA-256 sums of all files used while parsing.
+	Sums map[string]string `json:"sums"`
+}
+
+func (c *Cache) key(parser *Parser, filenames []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\n", cacheFormatVersion)
+	for _, root := range parser.roots {
+		fmt.Fprintf(h, "import %q\n", root.ref)
+	}
+	for _, filename := range filenames {
+		sum, err := parser.fileSum(filename)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %q %s\n", filename, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) load(parser *Parser, key string) ([]*desc.FileDescriptor, error) {
+	manifestBytes, err := os.ReadFile(c.path(key, ".json"))
+	if err != nil {
+		return nil, err
+	}
+	var manifest cacheManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, err
+	}
+	for filename, wantSum := range manifest.Sums {
+		sum, err := parser.fileSum(filename)
+		if err != nil {
+			return nil, err
+		}
+		if sum != wantSum {
+			return nil, fmt.Errorf("file %s has changed", filename)
+		}
+	}
+
+	setBytes, err := os.ReadFile(c.path(key, ".protoset"))
+	if err != nil {
+		return nil, err
+	}
+	set, err := UnmarshalDescriptorSet(setBytes)
+	if err != nil {
+		return nil, err
+	}
+	fileDescs, err := desc.CreateFileDescriptorsFromSet(set)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*desc.FileDescriptor, 0, len(manifest.Roots))
+	for _, root := range manifest.Roots {
+		fileDesc, ok := fileDescs[root]
+		if !ok {
+			return nil, fmt.Errorf("file %s missing in cache entry", root)
+		}
+		files = append(files, fileDesc)
+	}
+	return files, nil
+}
+
+func (c *Cache) store(parser *Parser, key string, files []*desc.FileDescriptor) error {
+	setBytes, err := MarshalDescriptorSet(files...)
+	if err != nil {
+		return err
+	}
+
+	manifest := cacheManifest{Sums: make(map[string]string)}
+	for _, file := range files {
+		manifest.Roots = append(manifest.Roots, file.GetName())
+	}
+	for _, fdp := range desc.ToFileDescriptorSet(files...).GetFile() {
+		sum, err := parser.fileSum(fdp.GetName())
+		if err != nil {
+			return err
+		}
+		manifest.Sums[fdp.GetName()] = sum
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	// The manifest is written last, entries without manifest are ignored.
+	if err := writeFileAtomic(c.path(key, ".protoset"), setBytes); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(key, ".json"), manifestBytes)
+}
+
+func (c *Cache) path(key, ext string) string {
+	return filepath.Join(c.dir, key+ext)
+}
+
+// fileSum returns the SHA-256 sum of the file with the given name, resolved
+// the same way the parser resolves it. Files that can't be read are provided
+// by the parser itself, like the well-known types, which are compiled from
+// the descriptors linked into the binary.
+func (p *Parser) fileSum(filename string) (string, error) {
+	r, err := p.parser.Accessor(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return builtinFileSum(filename, err)
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func builtinFileSum(filename string, notExistErr error) (string, error) {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(filename)
+	if err != nil {
+		return "", notExistErr
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(protodesc.ToFileDescriptorProto(fd))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "builtin:" + hex.EncodeToString(sum[:]), nil
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,196 @@
+package proto
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Conflict is a symbol that is defined differently by more than one source.
+type Conflict struct {
+	// Name is the fully-qualified name of the symbol.
+	Name string
+	// Definitions are the differing definitions, in order of precedence.
+	Definitions []Definition
+	// WireCompatible reports whether messages encoded with one definition can
+	// be decoded with the others.
+	WireCompatible bool
+	// Reason describes why the definitions aren't wire-compatible.
+	Reason string
+}
+
+// Definition is the definition of a symbol by a source.
+type Definition struct {
+	// Source is the index of the source that defines the symbol.
+	Source int
+	// File is the name of the file that defines the symbol.
+	File string
+}
+
+// FindConflicts compares the messages, enums and services of the given
+// sources and returns the ones that are defined differently by more than one
+// source, sorted by name. Symbols which are defined by files of the same name
+// with equal definitions aren't reported.
+func FindConflicts(sources ...DescriptorSource) []Conflict {
+	type definition struct {
+		Definition
+		desc desc.Descriptor
+	}
+
+	defs := make(map[string][]definition)
+	for i, src := range sources {
+		for name, d := range Symbols(src.Files()) {
+			switch d.(type) {
+			case *desc.MessageDescriptor, *desc.EnumDescriptor, *desc.ServiceDescriptor:
+			default:
+				continue
+			}
+			defs[name] = append(defs[name], definition{
+				Definition: Definition{Source: i, File: d.GetFile().GetName()},
+				desc:       d,
+			})
+		}
+	}
+
+	var conflicts []Conflict
+	for name, nameDefs := range defs {
+		first := nameDefs[0]
+		conflict := Conflict{Name: name, Definitions: []Definition{first.Definition}, WireCompatible: true}
+		for _, def := range nameDefs[1:] {
+			if def.File == first.File && proto.Equal(shallowProto(def.desc), shallowProto(first.desc)) {
+				continue
+			}
+			conflict.Definitions = append(conflict.Definitions, def.Definition)
+			if reason := wireIncompatibility(first.desc, def.desc); reason != "" && conflict.WireCompatible {
+				conflict.WireCompatible = false
+				conflict.Reason = reason
+			}
+		}
+		if len(conflict.Definitions) > 1 {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Name < conflicts[j].Name })
+	return conflicts
+}
+
+// shallowProto returns the descriptor proto of d without nested declarations,
+// which are compared on their own.
+func shallowProto(d desc.Descriptor) proto.Message {
+	switch d := d.(type) {
+	case *desc.MessageDescriptor:
+		dp := proto.Clone(d.AsDescriptorProto()).(*descriptorpb.DescriptorProto)
+		dp.NestedType = nil
+		dp.EnumType = nil
+		dp.Extension = nil
+		return dp
+	case *desc.EnumDescriptor:
+		return d.AsEnumDescriptorProto()
+	case *desc.ServiceDescriptor:
+		return d.AsServiceDescriptorProto()
+	default:
+		return nil
+	}
+}
+
+// wireIncompatibility returns why the definitions a and b of the same symbol
+// aren't wire-compatible, or an empty string if they are.
+func wireIncompatibility(a, b desc.Descriptor) string {
+	switch a := a.(type) {
+	case *desc.MessageDescriptor:
+		b, ok := b.(*desc.MessageDescriptor)
+		if !ok {
+			return "not a message in both definitions"
+		}
+		for _, fa := range a.GetFields() {
+			fb := b.FindFieldByNumber(fa.GetNumber())
+			if fb == nil {
+				continue
+			}
+			if reason := fieldIncompatibility(fa, fb); reason != "" {
+				return fmt.Sprintf("field %d: %s", fa.GetNumber(), reason)
+			}
+		}
+	case *desc.EnumDescriptor:
+		b, ok := b.(*desc.EnumDescriptor)
+		if !ok {
+			return "not an enum in both definitions"
+		}
+		// Like fields, values only declared by one definition are decoded as
+		// their number. Values whose name or number differs are decoded as a
+		// different value by the peer.
+		for _, va := range a.GetValues() {
+			if vb := b.FindValueByNumber(va.GetNumber()); vb != nil && vb.GetName() != va.GetName() {
+				return fmt.Sprintf("value %d: names %s and %s", va.GetNumber(), va.GetName(), vb.GetName())
+			}
+			if vb := b.FindValueByName(va.GetName()); vb != nil && vb.GetNumber() != va.GetNumber() {
+				return fmt.Sprintf("value %s: numbers %d and %d", va.GetName(), va.GetNumber(), vb.GetNumber())
+			}
+		}
+	case *desc.ServiceDescriptor:
+		b, ok := b.(*desc.ServiceDescriptor)
+		if !ok {
+			return "not a service in both definitions"
+		}
+		for _, ma := range a.GetMethods() {
+			mb := b.FindMethodByName(ma.GetName())
+			if mb == nil {
+				continue
+			}
+			switch {
+			case ma.GetInputType().GetFullyQualifiedName() != mb.GetInputType().GetFullyQualifiedName():
+				return fmt.Sprintf("method %s: input types differ", ma.GetName())
+			case ma.GetOutputType().GetFullyQualifiedName() != mb.GetOutputType().GetFullyQualifiedName():
+				return fmt.Sprintf("method %s: output types differ", ma.GetName())
+			case ma.IsClientStreaming() != mb.IsClientStreaming() || ma.IsServerStreaming() != mb.IsServerStreaming():
+				return fmt.Sprintf("method %s: streaming differs", ma.GetName())
+			}
+		}
+	}
+	return ""
+}
+
+// fieldIncompatibility returns why the fields a and b with the same number
+// aren't wire-compatible, or an empty string if they are.
+func fieldIncompatibility(a, b *desc.FieldDescriptor) string {
+	if a.IsRepeated() != b.IsRepeated() {
+		return "cardinality differs"
+	}
+	if a.IsMap() != b.IsMap() {
+		return "map and non-map"
+	}
+	typeA, typeB := wireCompatibleType(a), wireCompatibleType(b)
+	if typeA != typeB {
+		return fmt.Sprintf("types %s and %s", FieldTypeName(a), FieldTypeName(b))
+	}
+	return ""
+}
+
+// wireCompatibleType maps field types that can be exchanged without changing
+// the decoded values to the same name.
+func wireCompatibleType(fd *desc.FieldDescriptor) string {
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_BOOL, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return "varint"
+	case descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SINT64:
+		return "zigzag"
+	case descriptorpb.FieldDescriptorProto_TYPE_FIXED32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return "fixed32"
+	case descriptorpb.FieldDescriptorProto_TYPE_FIXED64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return "fixed64"
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return "bytes"
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		return "message " + fd.GetMessageType().GetFullyQualifiedName()
+	case descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return "group " + fd.GetMessageType().GetFullyQualifiedName()
+	default:
+		return fd.GetType().String()
+	}
+}
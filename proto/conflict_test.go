@@ -0,0 +1,86 @@
+package proto
+
+import "testing"
+
+func TestFindConflicts(t *testing.T) {
+	testCases := map[string]struct {
+		a, b           string
+		wantConflict   bool
+		wantCompatible bool
+	}{
+		"equal definitions": {
+			a: `message M { string a = 1; }`,
+			b: `message M { string a = 1; }`,
+		},
+		"added field": {
+			a:              `message M { string a = 1; int32 b = 2; }`,
+			b:              `message M { string a = 1; }`,
+			wantConflict:   true,
+			wantCompatible: true,
+		},
+		"compatible field types": {
+			a:              `message M { int32 a = 1; bytes b = 2; }`,
+			b:              `message M { uint64 a = 1; string b = 2; }`,
+			wantConflict:   true,
+			wantCompatible: true,
+		},
+		"incompatible field types": {
+			a:            `message M { int32 a = 1; }`,
+			b:            `message M { string a = 1; }`,
+			wantConflict: true,
+		},
+		"incompatible cardinality": {
+			a:            `message M { string a = 1; }`,
+			b:            `message M { repeated string a = 1; }`,
+			wantConflict: true,
+		},
+		"different nested message": {
+			a:            `message M { message N { int32 a = 1; } N n = 1; }`,
+			b:            `message M { message N { string a = 1; } N n = 1; }`,
+			wantConflict: true,
+		},
+		"enum values": {
+			a:              `enum E { A = 0; }`,
+			b:              `enum E { A = 0; B = 1; }`,
+			wantConflict:   true,
+			wantCompatible: true,
+		},
+		"renumbered enum value": {
+			a:            `enum E { A = 0; B = 1; }`,
+			b:            `enum E { A = 0; B = 2; }`,
+			wantConflict: true,
+		},
+		"reused enum value number": {
+			a:            `enum E { A = 0; B = 1; }`,
+			b:            `enum E { A = 0; C = 1; }`,
+			wantConflict: true,
+		},
+		"method types": {
+			a:            `message M {} message N {} service S { rpc Call(M) returns (M); }`,
+			b:            `message M {} message N {} service S { rpc Call(M) returns (N); }`,
+			wantConflict: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			a := mustFSSource(t, tc.a)
+			b := mustFSSource(t, tc.b)
+
+			conflicts := FindConflicts(a, b)
+
+			if !tc.wantConflict {
+				if len(conflicts) != 0 {
+					t.Fatalf("unexpected conflicts: %+v", conflicts)
+				}
+				return
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("expected one conflict, got %+v", conflicts)
+			}
+			if conflicts[0].WireCompatible != tc.wantCompatible {
+				t.Errorf("expected WireCompatible to be %t, got %+v", tc.wantCompatible, conflicts[0])
+			}
+		})
+	}
+}
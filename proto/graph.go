@@ -0,0 +1,463 @@
+package proto
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// IsWellKnownType reports whether d is declared in one of the well-known
+// proto files, like google/protobuf/any.proto.
+func IsWellKnownType(d desc.Descriptor) bool {
+	return strings.HasPrefix(d.GetFile().GetName(), "google/protobuf/")
+}
+
+// TypeReferences returns the messages and enums directly referenced by d. For
+// messages, these are the types of its fields, where map fields reference
+// the type of their values. For methods, these are the input and output
+// types, for services the ones of all methods. The types of messages packed
+// into google.protobuf.Any fields are only known at runtime and aren't
+// included, see AnyHints.
+func TypeReferences(d desc.Descriptor) []desc.Descriptor {
+	var refs []desc.Descriptor
+	seen := make(map[string]struct{})
+	add := func(ref desc.Descriptor) {
+		if ref == nil {
+			return
+		}
+		if _, ok := seen[ref.GetFullyQualifiedName()]; ok {
+			return
+		}
+		seen[ref.GetFullyQualifiedName()] = struct{}{}
+		refs = append(refs, ref)
+	}
+	addField := func(fd *desc.FieldDescriptor) {
+		if fd.IsMap() {
+			fd = fd.GetMapValueType()
+		}
+		if msg := fd.GetMessageType(); msg != nil {
+			add(msg)
+		} else if enum := fd.GetEnumType(); enum != nil {
+			add(enum)
+		}
+	}
+
+	switch d := d.(type) {
+	case *desc.ServiceDescriptor:
+		for _, method := range d.GetMethods() {
+			add(method.GetInputType())
+			add(method.GetOutputType())
+		}
+	case *desc.MethodDescriptor:
+		add(d.GetInputType())
+		add(d.GetOutputType())
+	case *desc.MessageDescriptor:
+		for _, fd := range d.GetFields() {
+			addField(fd)
+		}
+	case *desc.FieldDescriptor:
+		addField(d)
+	}
+	return refs
+}
+
+// anyHintRegexp matches type URLs like 'type.googleapis.com/pkg.Message',
+// capturing the message name.
+var anyHintRegexp = regexp.MustCompile(`[\w.-]+/([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)+)\b`)
+
+// AnyHints returns the names of the messages expected in the
+// google.protobuf.Any field fd, which are given as type URLs in the comments
+// of the field, like 'type.googleapis.com/pkg.Message'. Everything up to the
+// last '/' of a type URL is ignored, like in TypeResolver.FindMessageByURL.
+func AnyHints(fd *desc.FieldDescriptor) []string {
+	if fd.IsMap() {
+		fd = fd.GetMapValueType()
+	}
+	if msg := fd.GetMessageType(); msg == nil || msg.GetFullyQualifiedName() != "google.protobuf.Any" {
+		return nil
+	}
+	info := fd.GetSourceInfo()
+	var names []string
+	seen := make(map[string]struct{})
+	for _, c := range []string{info.GetLeadingComments(), info.GetTrailingComments()} {
+		for _, match := range anyHintRegexp.FindAllStringSubmatch(c, -1) {
+			if _, ok := seen[match[1]]; ok {
+				continue
+			}
+			seen[match[1]] = struct{}{}
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// WalkOptions control which types ReferencedTypes returns.
+type WalkOptions struct {
+	// MaxDepth limits how many references are followed from the root. Types
+	// directly referenced by the root have depth 1. Zero means no limit.
+	MaxDepth int
+	// ExcludeWellKnownTypes excludes well-known types and the types they
+	// reference.
+	ExcludeWellKnownTypes bool
+	// KeepNestedTypes includes types nested in the root or in another
+	// returned type.
+	KeepNestedTypes bool
+	// Source is used to look up the messages named by the AnyHints of
+	// google.protobuf.Any fields, which are followed like other references.
+	// Without Source, the types of Any payloads aren't known.
+	Source DescriptorSource
+}
+
+// references returns the types directly referenced by d, including the
+// messages of Any hints that can be found in the source.
+func (o WalkOptions) references(d desc.Descriptor) []desc.Descriptor {
+	refs := TypeReferences(d)
+	msg, ok := d.(*desc.MessageDescriptor)
+	if !ok || o.Source == nil {
+		return refs
+	}
+	seen := make(map[string]struct{}, len(refs))
+	for _, ref := range refs {
+		seen[ref.GetFullyQualifiedName()] = struct{}{}
+	}
+	for _, fd := range msg.GetFields() {
+		for _, name := range AnyHints(fd) {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			hinted, err := o.Source.FindSymbol(name)
+			if _, isMessage := hinted.(*desc.MessageDescriptor); err != nil || !isMessage {
+				continue
+			}
+			seen[name] = struct{}{}
+			refs = append(refs, hinted)
+		}
+	}
+	return refs
+}
+
+// ReferencedTypes returns the messages and enums transitively referenced by
+// root, each one once and in dependency order, so every type comes after the
+// types it references. The root itself isn't included. Unless KeepNestedTypes
+// is set, types nested in the root or in another returned type are omitted, as
+// they are part of the definition of their parent. With a Source, the messages
+// named by Any hints are followed as well.
+func ReferencedTypes(root desc.Descriptor, opts WalkOptions) []desc.Descriptor {
+	// Types are found breadth-first, so the depth of a type is the length of
+	// the shortest chain of references from the root.
+	depths := map[string]int{root.GetFullyQualifiedName(): 0}
+	queue := []desc.Descriptor{root}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		depth := depths[d.GetFullyQualifiedName()]
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			continue
+		}
+		for _, ref := range opts.references(d) {
+			if _, ok := depths[ref.GetFullyQualifiedName()]; ok {
+				continue
+			}
+			if opts.ExcludeWellKnownTypes && IsWellKnownType(ref) {
+				continue
+			}
+			depths[ref.GetFullyQualifiedName()] = depth + 1
+			queue = append(queue, ref)
+		}
+	}
+
+	// The found types are sorted depth-first, following only references
+	// between found types.
+	var types []desc.Descriptor
+	visited := map[string]struct{}{root.GetFullyQualifiedName(): {}}
+	var walk func(d desc.Descriptor)
+	walk = func(d desc.Descriptor) {
+		for _, ref := range opts.references(d) {
+			if _, ok := depths[ref.GetFullyQualifiedName()]; !ok {
+				continue
+			}
+			if _, ok := visited[ref.GetFullyQualifiedName()]; ok {
+				continue
+			}
+			visited[ref.GetFullyQualifiedName()] = struct{}{}
+			walk(ref)
+			types = append(types, ref)
+		}
+	}
+	walk(root)
+	if opts.KeepNestedTypes {
+		return types
+	}
+
+	included := make(map[string]struct{}, len(types)+1)
+	included[root.GetFullyQualifiedName()] = struct{}{}
+	for _, t := range types {
+		included[t.GetFullyQualifiedName()] = struct{}{}
+	}
+	var result []desc.Descriptor
+	for _, t := range types {
+		if !hasIncludedParent(t, included) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func hasIncludedParent(d desc.Descriptor, included map[string]struct{}) bool {
+	for parent := d.GetParent(); parent != nil; parent = parent.GetParent() {
+		if _, ok := parent.(*desc.MessageDescriptor); !ok {
+			return false
+		}
+		if _, ok := included[parent.GetFullyQualifiedName()]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferenceGraph is a reverse index of type references, which maps messages
+// and enums to the methods and fields that reference them.
+type ReferenceGraph struct {
+	users map[string][]desc.Descriptor
+}
+
+// NewReferenceGraph builds the reference graph of all methods, fields and
+// extensions declared in files. Extensions are fields of the message they
+// extend.
+func NewReferenceGraph(files []*desc.FileDescriptor) *ReferenceGraph {
+	g := &ReferenceGraph{users: make(map[string][]desc.Descriptor)}
+	for _, symbol := range sortedSymbols(Symbols(files)) {
+		switch symbol := symbol.(type) {
+		case *desc.MethodDescriptor:
+			for _, ref := range TypeReferences(symbol) {
+				g.add(ref, symbol)
+			}
+		case *desc.FieldDescriptor:
+			// Fields of map entries are represented by the map field.
+			if symbol.GetOwner().IsMapEntry() {
+				continue
+			}
+			for _, ref := range TypeReferences(symbol) {
+				g.add(ref, symbol)
+			}
+		}
+	}
+	return g
+}
+
+func (g *ReferenceGraph) add(ref, user desc.Descriptor) {
+	name := ref.GetFullyQualifiedName()
+	g.users[name] = append(g.users[name], user)
+}
+
+// Usages returns the methods and fields that reference the type with the
+// given fully-qualified name, directly or through other messages. Each usage
+// is reported once, with the shortest chain of messages it references the
+// type through. Direct usages come first.
+func (g *ReferenceGraph) Usages(typeName string) Usages {
+	usages := Usages{Type: typeName, Methods: []Usage{}, Fields: []Usage{}}
+
+	type entry struct {
+		name string
+		// via are the messages between the users of name and the type.
+		via []string
+	}
+	queue := []entry{{name: typeName}}
+	visited := map[string]struct{}{typeName: {}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, user := range g.users[cur.name] {
+			switch user := user.(type) {
+			case *desc.MethodDescriptor:
+				if user.GetInputType().GetFullyQualifiedName() == cur.name {
+					usages.Methods = append(usages.Methods, Usage{Name: user.GetFullyQualifiedName(), Role: "input", Via: cur.via})
+				}
+				if user.GetOutputType().GetFullyQualifiedName() == cur.name {
+					usages.Methods = append(usages.Methods, Usage{Name: user.GetFullyQualifiedName(), Role: "output", Via: cur.via})
+				}
+			case *desc.FieldDescriptor:
+				usages.Fields = append(usages.Fields, Usage{Name: user.GetFullyQualifiedName(), Via: cur.via})
+				owner := user.GetOwner().GetFullyQualifiedName()
+				if _, ok := visited[owner]; ok {
+					continue
+				}
+				visited[owner] = struct{}{}
+				via := append([]string{owner}, cur.via...)
+				queue = append(queue, entry{name: owner, via: via})
+			}
+		}
+	}
+
+	sortUsages(usages.Methods)
+	sortUsages(usages.Fields)
+	return usages
+}
+
+func sortUsages(usages []Usage) {
+	sort.SliceStable(usages, func(i, j int) bool {
+		if len(usages[i].Via) != len(usages[j].Via) {
+			return len(usages[i].Via) < len(usages[j].Via)
+		}
+		return usages[i].Name < usages[j].Name
+	})
+}
+
+func sortedSymbols(symbols map[string]desc.Descriptor) []desc.Descriptor {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := make([]desc.Descriptor, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, symbols[name])
+	}
+	return sorted
+}
+
+// TypeGraph is the graph of services, methods, messages and enums and the
+// references between them.
+type TypeGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// GraphNode is a symbol in a TypeGraph. Kind is one of "service", "method",
+// "message" or "enum".
+type GraphNode struct {
+	Name string
+	Kind string
+}
+
+// GraphEdge is a reference from one symbol to another. Services reference
+// their methods, methods their input and output types, labeled "input" and
+// "output", and messages the types of their fields.
+type GraphEdge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// GraphOptions control which symbols and edges a TypeGraph contains.
+type GraphOptions struct {
+	// Packages limits the graph to symbols in the given packages and their
+	// sub-packages. If empty, symbols of all packages are included.
+	Packages []string
+	// Root limits the graph to symbols reachable from the given symbol.
+	Root desc.Descriptor
+	// Fields adds an edge labeled with the field name for every field of a
+	// message. By default, there is one unlabeled edge per referenced type.
+	Fields bool
+}
+
+// NewTypeGraph builds the type graph of the symbols declared in files.
+func NewTypeGraph(files []*desc.FileDescriptor, opts GraphOptions) *TypeGraph {
+	var candidates []desc.Descriptor
+	if opts.Root != nil {
+		candidates = reachableSymbols(opts.Root)
+	} else {
+		candidates = sortedSymbols(Symbols(files))
+	}
+
+	g := &TypeGraph{}
+	nodes := make(map[string]desc.Descriptor)
+	for _, symbol := range candidates {
+		kind := graphNodeKind(symbol)
+		if kind == "" || !inPackages(symbol, opts.Packages) {
+			continue
+		}
+		nodes[symbol.GetFullyQualifiedName()] = symbol
+		g.Nodes = append(g.Nodes, GraphNode{Name: symbol.GetFullyQualifiedName(), Kind: kind})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+
+	addEdge := func(from desc.Descriptor, to desc.Descriptor, label string) {
+		if _, ok := nodes[to.GetFullyQualifiedName()]; !ok {
+			return
+		}
+		g.Edges = append(g.Edges, GraphEdge{From: from.GetFullyQualifiedName(), To: to.GetFullyQualifiedName(), Label: label})
+	}
+	for _, node := range g.Nodes {
+		switch symbol := nodes[node.Name].(type) {
+		case *desc.ServiceDescriptor:
+			for _, method := range symbol.GetMethods() {
+				addEdge(symbol, method, "")
+			}
+		case *desc.MethodDescriptor:
+			addEdge(symbol, symbol.GetInputType(), "input")
+			addEdge(symbol, symbol.GetOutputType(), "output")
+		case *desc.MessageDescriptor:
+			if !opts.Fields {
+				for _, ref := range TypeReferences(symbol) {
+					addEdge(symbol, ref, "")
+				}
+				continue
+			}
+			for _, field := range symbol.GetFields() {
+				for _, ref := range TypeReferences(field) {
+					addEdge(symbol, ref, field.GetName())
+				}
+			}
+		}
+	}
+	return g
+}
+
+// reachableSymbols returns root and all services, methods, messages and enums
+// reachable from it.
+func reachableSymbols(root desc.Descriptor) []desc.Descriptor {
+	symbols := []desc.Descriptor{root}
+	visited := map[string]struct{}{root.GetFullyQualifiedName(): {}}
+	for i := 0; i < len(symbols); i++ {
+		refs := TypeReferences(symbols[i])
+		if svc, ok := symbols[i].(*desc.ServiceDescriptor); ok {
+			refs = nil
+			for _, method := range svc.GetMethods() {
+				refs = append(refs, method)
+			}
+		}
+		for _, ref := range refs {
+			if _, ok := visited[ref.GetFullyQualifiedName()]; ok {
+				continue
+			}
+			visited[ref.GetFullyQualifiedName()] = struct{}{}
+			symbols = append(symbols, ref)
+		}
+	}
+	return symbols
+}
+
+func graphNodeKind(d desc.Descriptor) string {
+	switch d := d.(type) {
+	case *desc.ServiceDescriptor:
+		return "service"
+	case *desc.MethodDescriptor:
+		return "method"
+	case *desc.MessageDescriptor:
+		if d.IsMapEntry() {
+			return ""
+		}
+		return "message"
+	case *desc.EnumDescriptor:
+		return "enum"
+	default:
+		return ""
+	}
+}
+
+func inPackages(d desc.Descriptor, packages []string) bool {
+	if len(packages) == 0 {
+		return true
+	}
+	pkg := d.GetFile().GetPackage()
+	for _, p := range packages {
+		if pkg == p || strings.HasPrefix(pkg, p+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package proto
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// importRoot is a location the parser resolves proto files from.
+type importRoot struct {
+	// ref is the location as given by the user.
+	ref  string
+	fsys fs.FS
+}
+
+// OpenImportPath opens a location proto files can be loaded from. It returns
+// the file system of the location and the directory within that file system
+// the ref points to.
+//
+// The ref can be one of:
+//   - a directory on the local file system,
+//   - a .zip, .tar, .tar.gz or .tgz archive,
+//   - a Go module reference 'module@version[/subdir]', which is resolved against
+//     the local module cache. The network is never accessed. Files of a module
+//     are named by their import path, e.g. 'github.com/containerd/containerd/api/types/mount.proto'.
+func OpenImportPath(ref string) (fs.FS, string, error) {
+	info, err := os.Stat(ref)
+	switch {
+	case err == nil && info.IsDir():
+		return os.DirFS(ref), ".", nil
+	case err == nil && isArchive(ref):
+		fsys, err := openArchive(ref)
+		return fsys, ".", err
+	case err == nil:
+		return nil, "", fmt.Errorf("import path %s is neither a directory nor an archive", ref)
+	case IsModuleRef(ref):
+		return openModule(ref)
+	default:
+		return nil, "", fmt.Errorf("opening import path: %w", err)
+	}
+}
+
+// IsImportPath reports whether the given proto argument refers to an import
+// path instead of a single proto file.
+func IsImportPath(ref string) bool {
+	if info, err := os.Stat(ref); err == nil {
+		return info.IsDir() || isArchive(ref)
+	}
+	return IsModuleRef(ref)
+}
+
+// IsModuleRef reports whether ref has the form 'module@version[/subdir]'.
+// Like in Go, the first element of the module path must contain a dot, so
+// local paths containing '@', like './vendor@old', aren't module references.
+func IsModuleRef(ref string) bool {
+	modPath, version, _, ok := splitModuleRef(ref)
+	return ok && isModulePath(modPath) && version != ""
+}
+
+// isModulePath reports whether p looks like a module path, whose first
+// element is a domain name.
+func isModulePath(p string) bool {
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "/") {
+		return false
+	}
+	first, _, _ := strings.Cut(p, "/")
+	return first != "." && first != ".." && strings.Contains(first, ".")
+}
+
+// FindProtoFiles returns the sorted names of all proto files in dir and its
+// subdirectories.
+func FindProtoFiles(fsys fs.FS, dir string) ([]string, error) {
+	var filenames []string
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && path.Ext(name) == ".proto" {
+			filenames = append(filenames, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking directory: %w", err)
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// openFS opens the file in fsys. Names that aren't valid within a fs.FS
+// are reported as not existing.
+func openFS(fsys fs.FS, name string) (io.ReadCloser, error) {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "./")
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.Open(name)
+}
+
+func isArchive(name string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func openArchive(name string) (fs.FS, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	if strings.HasSuffix(name, ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, fmt.Errorf("reading zip archive %s: %w", name, err)
+		}
+		return r, nil
+	}
+
+	var r io.Reader = bytes.NewReader(b)
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip archive %s: %w", name, err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	fsys, err := tarToFS(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading tar archive %s: %w", name, err)
+	}
+	return fsys, nil
+}
+
+// tarToFS converts a tar stream into an in-memory zip archive, as zip.Reader
+// already implements fs.FS.
+func tarToFS(r io.Reader) (fs.FS, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		if !fs.ValidPath(name) {
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// openModule resolves a module reference against the local module cache. The
+// extracted module directory is preferred, the downloaded module zip is used
+// as fallback.
+func openModule(ref string) (fs.FS, string, error) {
+	modPath, version, subdir, _ := splitModuleRef(ref)
+	modCache, err := goModCache()
+	if err != nil {
+		return nil, "", err
+	}
+	escPath, err := escapeModulePath(modPath)
+	if err != nil {
+		return nil, "", err
+	}
+	escVersion, err := escapeModulePath(version)
+	if err != nil {
+		return nil, "", err
+	}
+	dir := path.Join(modPath, subdir)
+
+	modDir := filepath.Join(modCache, filepath.FromSlash(escPath)+"@"+escVersion)
+	if info, err := os.Stat(modDir); err == nil && info.IsDir() {
+		return &prefixFS{prefix: modPath, fsys: os.DirFS(modDir)}, dir, nil
+	}
+
+	zipPath := filepath.Join(modCache, "cache", "download", filepath.FromSlash(escPath), "@v", escVersion+".zip")
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("module %s@%s not found in module cache %s", modPath, version, modCache)
+	}
+	// Files in module zips are prefixed with 'module@version/'.
+	modFS, err := fs.Sub(zr, modPath+"@"+version)
+	if err != nil {
+		return nil, "", err
+	}
+	return &prefixFS{prefix: modPath, fsys: modFS}, dir, nil
+}
+
+func splitModuleRef(ref string) (modPath, version, subdir string, ok bool) {
+	modPath, rest, ok := strings.Cut(ref, "@")
+	if !ok {
+		return "", "", "", false
+	}
+	version, subdir, _ = strings.Cut(rest, "/")
+	return modPath, version, subdir, true
+}
+
+func goModCache() (string, error) {
+	if modCache := os.Getenv("GOMODCACHE"); modCache != "" {
+		return modCache, nil
+	}
+	if gopath := filepath.SplitList(os.Getenv("GOPATH")); len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating module cache: %w", err)
+	}
+	return filepath.Join(home, "go", "pkg", "mod"), nil
+}
+
+// escapeModulePath applies the case-encoding of the module cache, which
+// replaces every upper-case letter by '!' followed by the lower-case letter.
+func escapeModulePath(p string) (string, error) {
+	var b strings.Builder
+	for _, r := range p {
+		if r == '!' || r >= unicode.MaxASCII {
+			return "", fmt.Errorf("invalid character %q in module path %s", r, p)
+		}
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// prefixFS serves the files of fsys below prefix.
+type prefixFS struct {
+	prefix string
+	fsys   fs.FS
+}
+
+func (p *prefixFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == p.prefix {
+		return p.fsys.Open(".")
+	}
+	rel, ok := strings.CutPrefix(name, p.prefix+"/")
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return p.fsys.Open(rel)
+}
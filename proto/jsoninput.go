@@ -0,0 +1,282 @@
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// explainJSONError checks the JSON input b against the descriptor md and
+// returns an error naming the path, the expected type and close matches of
+// the first invalid field. It is used to improve the terse errors of
+// protojson and returns nil if it can't find the problem.
+func explainJSONError(md protoreflect.MessageDescriptor, b []byte, allowUnknownFields bool) error {
+	var v any
+	if err := decodeJSON(b, &v); err != nil {
+		return nil
+	}
+	c := jsonChecker{allowUnknownFields: allowUnknownFields}
+	return c.checkMessage(md, v, "")
+}
+
+type jsonChecker struct {
+	allowUnknownFields bool
+}
+
+func (c jsonChecker) checkMessage(md protoreflect.MessageDescriptor, v any, path string) error {
+	// Well-known types have special JSON representations, which are left to
+	// protojson.
+	if v == nil || strings.HasPrefix(string(md.FullName()), "google.protobuf.") {
+		return nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return invalidValueError(path, "message "+string(md.FullName()), v)
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fd := md.Fields().ByJSONName(key)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(key))
+		}
+		if fd == nil {
+			// Extensions are resolved by protojson.
+			if c.allowUnknownFields || strings.HasPrefix(key, "[") {
+				continue
+			}
+			return unknownFieldError(md, key, path)
+		}
+		if err := c.checkField(fd, obj[key], joinJSONPath(path, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c jsonChecker) checkField(fd protoreflect.FieldDescriptor, v any, path string) error {
+	if v == nil {
+		return nil
+	}
+	switch {
+	case fd.IsMap():
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return invalidValueError(path, "map of "+jsonTypeName(fd.MapValue()), v)
+		}
+		for key, value := range obj {
+			if err := c.checkValue(fd.MapValue(), value, fmt.Sprintf("%s[%q]", path, key)); err != nil {
+				return err
+			}
+		}
+	case fd.IsList():
+		list, ok := v.([]any)
+		if !ok {
+			return invalidValueError(path, "list of "+jsonTypeName(fd), v)
+		}
+		for i, value := range list {
+			if err := c.checkValue(fd, value, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return c.checkValue(fd, v, path)
+	}
+	return nil
+}
+
+func (c jsonChecker) checkValue(fd protoreflect.FieldDescriptor, v any, path string) error {
+	if v == nil {
+		return nil
+	}
+	valid := true
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return c.checkMessage(fd.Message(), v, path)
+	case protoreflect.EnumKind:
+		return checkEnum(fd.Enum(), v, path)
+	case protoreflect.BoolKind:
+		_, valid = v.(bool)
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		_, valid = v.(string)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		switch v := v.(type) {
+		case json.Number:
+		case string:
+			_, err := strconv.ParseFloat(v, 64)
+			valid = err == nil || v == "NaN" || v == "Infinity" || v == "-Infinity"
+		default:
+			valid = false
+		}
+	default:
+		// Integers may be given as numbers or strings.
+		switch v := v.(type) {
+		case json.Number:
+			valid = isIntegralNumber(v.String())
+		case string:
+			_, err := strconv.ParseInt(v, 10, 64)
+			_, uerr := strconv.ParseUint(v, 10, 64)
+			valid = err == nil || uerr == nil || isIntegralNumber(v)
+		default:
+			valid = false
+		}
+	}
+	if !valid {
+		return invalidValueError(path, jsonTypeName(fd), v)
+	}
+	return nil
+}
+
+// jsonNumberRegexp matches JSON numbers, capturing the integer part, the
+// fraction and the exponent.
+var jsonNumberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(?:\.([0-9]+))?(?:[eE]([+-]?[0-9]+))?$`)
+
+// isIntegralNumber reports whether s is a JSON number with an integral value.
+// Like protojson, numbers with a fraction or an exponent are accepted for
+// integer fields if their value is integral, e.g. 1e2 or 100.0.
+func isIntegralNumber(s string) bool {
+	match := jsonNumberRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return false
+	}
+	digits := match[1] + match[2]
+	exp := 0
+	if match[3] != "" {
+		var err error
+		if exp, err = strconv.Atoi(match[3]); err != nil {
+			return false
+		}
+	}
+	// All digits after the decimal point must be zero.
+	point := len(match[1]) + exp
+	if point < 0 {
+		point = 0
+	}
+	if point >= len(digits) {
+		return true
+	}
+	return strings.Trim(digits[point:], "0") == ""
+}
+
+func checkEnum(ed protoreflect.EnumDescriptor, v any, path string) error {
+	if ed.FullName() == "google.protobuf.NullValue" {
+		return nil
+	}
+	switch v := v.(type) {
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return nil
+		}
+	case string:
+		if ed.Values().ByName(protoreflect.Name(v)) != nil {
+			return nil
+		}
+		var names []string
+		for i := 0; i < ed.Values().Len(); i++ {
+			names = append(names, string(ed.Values().Get(i).Name()))
+		}
+		err := invalidValueError(path, "enum "+string(ed.FullName()), v)
+		if suggestions := closeNames(v, names); len(suggestions) > 0 {
+			return fmt.Errorf("%w, did you mean %s?", err, joinList(quoteAll(suggestions), "or"))
+		}
+		return fmt.Errorf("%w, valid values are %s", err, joinList(names, "and"))
+	}
+	return invalidValueError(path, "enum "+string(ed.FullName()), v)
+}
+
+func unknownFieldError(md protoreflect.MessageDescriptor, key, path string) error {
+	var names []string
+	for i := 0; i < md.Fields().Len(); i++ {
+		names = append(names, md.Fields().Get(i).JSONName())
+	}
+	err := fmt.Errorf("unknown field %s of message %s", joinJSONPath(path, key), md.FullName())
+	if suggestions := closeNames(key, names); len(suggestions) > 0 {
+		var paths []string
+		for _, s := range suggestions {
+			paths = append(paths, joinJSONPath(path, s))
+		}
+		return fmt.Errorf("%w, did you mean %s?", err, joinList(paths, "or"))
+	}
+	return err
+}
+
+func invalidValueError(path, expected string, v any) error {
+	if path == "" {
+		return fmt.Errorf("invalid value: expected %s, got %s", expected, describeJSONValue(v))
+	}
+	return fmt.Errorf("invalid value for %s: expected %s, got %s", path, expected, describeJSONValue(v))
+}
+
+// jsonTypeName returns the type of a field as written in proto files, with
+// fully-qualified names for messages and enums.
+func jsonTypeName(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "message " + string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		return "enum " + string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+func describeJSONValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "string " + strconv.Quote(v)
+	case json.Number:
+		return "number " + v.String()
+	case bool:
+		return "bool " + strconv.FormatBool(v)
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// closeNames returns the names with the smallest edit distance to name,
+// ignoring case.
+func closeNames(name string, names []string) []string {
+	maxDistance := len(name)/3 + 1
+	best := maxDistance + 1
+	var matches []string
+	for _, candidate := range names {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		switch {
+		case d > maxDistance || d > best:
+		case d < best:
+			best = d
+			matches = []string{candidate}
+		default:
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func quoteAll(items []string) []string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return quoted
+}
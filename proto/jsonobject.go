@@ -0,0 +1,140 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The JSON produced by protojson is modified in a few places, e.g. to restore
+// the type URLs of Any payloads. Decoding it into map[string]any and encoding
+// it with json.Marshal would sort the fields and escape HTML characters, so
+// the helpers below keep the document as protojson wrote it.
+
+// jsonObject is a decoded JSON object that keeps the order of its members.
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]any)}
+}
+
+// set sets the member with the given key, which is appended if it's new.
+func (o *jsonObject) set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// delete removes the member with the given key.
+func (o *jsonObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// decodeOrderedJSON decodes b like decodeJSON, but objects are decoded as
+// *jsonObject.
+func decodeOrderedJSON(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeOrderedValue(dec)
+}
+
+func decodeOrderedValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), v)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			v, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := dec.Token()
+		return list, err
+	default:
+		return tok, nil
+	}
+}
+
+// marshalOrderedJSON returns the compact JSON encoding of v. Members of
+// *jsonObject values keep their order and HTML characters aren't escaped.
+func marshalOrderedJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeOrderedJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeOrderedJSON writes v, which may contain *jsonObject values, to buf.
+// Like protojson, HTML characters aren't escaped.
+func encodeOrderedJSON(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeOrderedJSON(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeOrderedJSON(buf, v.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeOrderedJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// Remove the newline added by the encoder.
+		buf.Truncate(buf.Len() - 1)
+	}
+	return nil
+}
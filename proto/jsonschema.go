@@ -0,0 +1,256 @@
+package proto
+
+import (
+	"math"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// JSONSchemaDialect is the JSON Schema version of generated schemas.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaGenerator generates JSON Schemas of messages that accept the same
+// input as protojson: fields are named by their JSON name, integers may be
+// given as strings, bytes are base64 encoded, enums are given by name or
+// number, null leaves a field unset and well-known types have their special
+// JSON representation. Only one field
+// of a oneof may be set. Like protojson, fields may also be given by their
+// proto name, but not by both names, and extensions by their name in
+// brackets, like '[pkg.ext]'. Other unknown fields aren't allowed.
+//
+// Every message is a separate definition, which is referenced by its
+// fully-qualified name.
+type SchemaGenerator struct {
+	// RefPrefix is the prefix of references to definitions. Defaults to
+	// "#/$defs/".
+	RefPrefix string
+
+	defs map[string]any
+}
+
+// Ref returns a schema referencing the definition of md, and adds the
+// definitions of md and all messages it references to the generator.
+func (g *SchemaGenerator) Ref(md *desc.MessageDescriptor) map[string]any {
+	name := md.GetFullyQualifiedName()
+	if g.defs == nil {
+		g.defs = make(map[string]any)
+	}
+	if _, ok := g.defs[name]; !ok {
+		// Mark the definition first, messages can be recursive.
+		g.defs[name] = nil
+		g.defs[name] = g.messageSchema(md)
+	}
+	prefix := g.RefPrefix
+	if prefix == "" {
+		prefix = "#/$defs/"
+	}
+	return map[string]any{"$ref": prefix + name}
+}
+
+// Definitions returns the definitions of all messages referenced so far,
+// keyed by their fully-qualified name.
+func (g *SchemaGenerator) Definitions() map[string]any {
+	return g.defs
+}
+
+// Document returns a self-contained JSON Schema document for md.
+func (g *SchemaGenerator) Document(md *desc.MessageDescriptor) map[string]any {
+	doc := map[string]any{
+		"$schema": JSONSchemaDialect,
+		"title":   md.GetFullyQualifiedName(),
+	}
+	for k, v := range g.Ref(md) {
+		doc[k] = v
+	}
+	doc["$defs"] = g.Definitions()
+	return doc
+}
+
+func (g *SchemaGenerator) messageSchema(md *desc.MessageDescriptor) map[string]any {
+	if schema := wellKnownTypeSchema(g, md); schema != nil {
+		return withDescription(schema, md)
+	}
+
+	properties := make(map[string]any)
+	for _, fd := range md.GetFields() {
+		fieldSchema := withDescription(nullable(g.fieldSchema(fd)), fd)
+		properties[fd.GetJSONName()] = fieldSchema
+		if fd.GetName() != fd.GetJSONName() {
+			properties[fd.GetName()] = fieldSchema
+		}
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(md.GetExtensionRanges()) > 0 {
+		// Extensions are only known to the resolver.
+		schema["patternProperties"] = map[string]any{`^\[.+\]$`: map[string]any{}}
+	}
+
+	var exclusive []any
+	for _, fd := range md.GetFields() {
+		// A field may be given by either name, but not by both.
+		if fd.GetName() != fd.GetJSONName() {
+			exclusive = append(exclusive, map[string]any{"not": map[string]any{"required": []any{fd.GetJSONName(), fd.GetName()}}})
+		}
+	}
+	for _, oneof := range md.GetOneOfs() {
+		if oneof.IsSynthetic() {
+			continue
+		}
+		// Exactly one of: one of the fields is set, or none is.
+		var choices, anySet []any
+		for _, fd := range oneof.GetChoices() {
+			required := map[string]any{"required": []any{fd.GetJSONName()}}
+			if fd.GetName() != fd.GetJSONName() {
+				required = map[string]any{"anyOf": []any{required, map[string]any{"required": []any{fd.GetName()}}}}
+			}
+			choices = append(choices, required)
+			anySet = append(anySet, required)
+		}
+		choices = append(choices, map[string]any{"not": map[string]any{"anyOf": anySet}})
+		exclusive = append(exclusive, map[string]any{"oneOf": choices})
+	}
+	if len(exclusive) > 0 {
+		schema["allOf"] = exclusive
+	}
+	return withDescription(schema, md)
+}
+
+func (g *SchemaGenerator) fieldSchema(fd *desc.FieldDescriptor) map[string]any {
+	if fd.IsMap() {
+		schema := map[string]any{
+			"type":                 "object",
+			"additionalProperties": g.singularSchema(fd.GetMapValueType()),
+		}
+		switch fd.GetMapKeyType().GetType() {
+		case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+			schema["propertyNames"] = map[string]any{"enum": []any{"true", "false"}}
+		default:
+			schema["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		return schema
+	}
+	if fd.IsRepeated() {
+		return map[string]any{"type": "array", "items": g.singularSchema(fd)}
+	}
+	return g.singularSchema(fd)
+}
+
+func (g *SchemaGenerator) singularSchema(fd *desc.FieldDescriptor) map[string]any {
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return g.Ref(fd.GetMessageType())
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return enumSchema(fd.GetEnumType())
+	default:
+		return scalarSchema(fd.GetType())
+	}
+}
+
+func scalarSchema(t descriptorpb.FieldDescriptorProto_Type) map[string]any {
+	switch t {
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return map[string]any{"type": "boolean"}
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return map[string]any{"type": "string"}
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		// protojson also accepts 32-bit integers as strings.
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32},
+			map[string]any{"type": "string", "pattern": "^-?[0-9]+$"},
+		}}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": 0, "maximum": math.MaxUint32},
+			map[string]any{"type": "string", "pattern": "^[0-9]+$"},
+		}}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		// protojson writes 64-bit integers as strings, but accepts numbers.
+		return map[string]any{"type": []any{"string", "integer"}, "pattern": "^-?[0-9]+$"}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		return map[string]any{"type": []any{"string", "integer"}, "pattern": "^[0-9]+$", "minimum": 0}
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"enum": []any{"NaN", "Infinity", "-Infinity"}},
+		}}
+	default:
+		return map[string]any{}
+	}
+}
+
+func enumSchema(ed *desc.EnumDescriptor) map[string]any {
+	if ed.GetFullyQualifiedName() == "google.protobuf.NullValue" {
+		return map[string]any{"type": "null"}
+	}
+	var names []any
+	for _, vd := range ed.GetValues() {
+		names = append(names, vd.GetName())
+	}
+	// protojson writes enums by name, but also accepts their number.
+	schema := map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string", "enum": names},
+			map[string]any{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32},
+		},
+		"title": ed.GetFullyQualifiedName(),
+	}
+	return withDescription(schema, ed)
+}
+
+// nullable allows null in place of a field, which protojson treats like an
+// unset field.
+func nullable(schema map[string]any) map[string]any {
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// wellKnownTypeSchema returns the schema of well-known types that have a
+// special JSON representation, or nil for all other messages.
+func wellKnownTypeSchema(g *SchemaGenerator, md *desc.MessageDescriptor) map[string]any {
+	switch md.GetFullyQualifiedName() {
+	case "google.protobuf.Any":
+		return map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"@type": map[string]any{"type": "string"}},
+			"required":   []any{"@type"},
+		}
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string"}
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.Value":
+		return map[string]any{}
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array"}
+	case "google.protobuf.Empty":
+		return map[string]any{"type": "object", "additionalProperties": false}
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue",
+		"google.protobuf.BytesValue":
+		return g.singularSchema(md.FindFieldByName("value"))
+	default:
+		return nil
+	}
+}
+
+func withDescription(schema map[string]any, d desc.Descriptor) map[string]any {
+	if c := comments(d); c != "" {
+		schema["description"] = c
+	}
+	return schema
+}
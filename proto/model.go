@@ -0,0 +1,340 @@
+package proto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/proto"
+)
+
+// The types below are the machine-readable description of proto symbols, as
+// printed by the list and describe commands with --output json or yaml.
+// They are part of ttrpcurl's interface: fields may be added, but existing
+// fields must not be renamed, removed or change their meaning.
+//
+// Names of services, methods, messages and enums are fully-qualified, names of
+// fields and enum values are not. Types are written as in proto files, but
+// with fully-qualified names for message and enum types. Comments are the
+// leading comments of the declaration. Options are the options set on the
+// declaration in their JSON representation, custom options are keyed by
+// their name in brackets, like '[pkg.option]'.
+
+// Description is the description of a single symbol. Kind is one of
+// "service", "method", "message", "enum" or "field", and the field of the
+// same name is set. Extensions are the known extensions of a message.
+// References are the descriptions of the types the symbol references, if
+// requested.
+type Description struct {
+	Kind       string        `json:"kind" yaml:"kind"`
+	Service    *Service      `json:"service,omitempty" yaml:"service,omitempty"`
+	Method     *Method       `json:"method,omitempty" yaml:"method,omitempty"`
+	Message    *Message      `json:"message,omitempty" yaml:"message,omitempty"`
+	Enum       *Enum         `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Field      *Field        `json:"field,omitempty" yaml:"field,omitempty"`
+	Extensions []Extension   `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	References []Description `json:"references,omitempty" yaml:"references,omitempty"`
+}
+
+// ServiceList is a list of services.
+type ServiceList struct {
+	Services []Service `json:"services" yaml:"services"`
+}
+
+// FileList is a list of proto files.
+type FileList struct {
+	Files []File `json:"files" yaml:"files"`
+}
+
+// File describes a proto file. Imports are the names of the files it imports.
+type File struct {
+	Name    string   `json:"name" yaml:"name"`
+	Package string   `json:"package" yaml:"package"`
+	Imports []string `json:"imports" yaml:"imports"`
+}
+
+type Service struct {
+	Name     string         `json:"name" yaml:"name"`
+	File     string         `json:"file" yaml:"file"`
+	Comments string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options  map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+	Methods  []Method       `json:"methods" yaml:"methods"`
+}
+
+// Method describes a method. Streaming is its StreamingKind.
+type Method struct {
+	Name       string         `json:"name" yaml:"name"`
+	InputType  string         `json:"inputType" yaml:"inputType"`
+	OutputType string         `json:"outputType" yaml:"outputType"`
+	Streaming  string         `json:"streaming" yaml:"streaming"`
+	Comments   string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options    map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// Message describes a message, including its nested messages and enums.
+type Message struct {
+	Name     string         `json:"name" yaml:"name"`
+	File     string         `json:"file" yaml:"file"`
+	Comments string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options  map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+	Fields   []Field        `json:"fields" yaml:"fields"`
+	Messages []Message      `json:"messages,omitempty" yaml:"messages,omitempty"`
+	Enums    []Enum         `json:"enums,omitempty" yaml:"enums,omitempty"`
+}
+
+// Field describes a field. Label is one of "optional", "required", "repeated"
+// or "map", Oneof is the name of the oneof the field is part of.
+type Field struct {
+	Name     string         `json:"name" yaml:"name"`
+	Number   int32          `json:"number" yaml:"number"`
+	Type     string         `json:"type" yaml:"type"`
+	Label    string         `json:"label" yaml:"label"`
+	JSONName string         `json:"jsonName" yaml:"jsonName"`
+	Oneof    string         `json:"oneof,omitempty" yaml:"oneof,omitempty"`
+	Comments string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options  map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// Extension describes an extension field. Unlike for fields, the name is
+// fully-qualified, as extensions are declared outside of the message they
+// extend. File is the file declaring the extension.
+type Extension struct {
+	Field `yaml:",inline"`
+	File  string `json:"file" yaml:"file"`
+}
+
+type Enum struct {
+	Name     string         `json:"name" yaml:"name"`
+	File     string         `json:"file" yaml:"file"`
+	Comments string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options  map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+	Values   []EnumValue    `json:"values" yaml:"values"`
+}
+
+type EnumValue struct {
+	Name     string         `json:"name" yaml:"name"`
+	Number   int32          `json:"number" yaml:"number"`
+	Comments string         `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Options  map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// Usages are the methods and fields that reference a type.
+type Usages struct {
+	Type    string  `json:"type" yaml:"type"`
+	Methods []Usage `json:"methods" yaml:"methods"`
+	Fields  []Usage `json:"fields" yaml:"fields"`
+}
+
+// Usage is a method or field that references a type. For methods, Role is
+// either "input" or "output". Via are the messages through which the type is
+// referenced, starting with the one the method or field references. It is
+// empty if the type is referenced directly.
+type Usage struct {
+	Name string   `json:"name" yaml:"name"`
+	Role string   `json:"role,omitempty" yaml:"role,omitempty"`
+	Via  []string `json:"via,omitempty" yaml:"via,omitempty"`
+}
+
+// Describer converts descriptors into their machine-readable description.
+type Describer struct {
+	// Resolver is used to resolve custom options. If nil, the global
+	// registry is used, and custom options unknown to it are omitted.
+	Resolver Resolver
+}
+
+// Describe describes the given symbol.
+func (d Describer) Describe(symbol desc.Descriptor) (Description, error) {
+	switch symbol := symbol.(type) {
+	case *desc.ServiceDescriptor:
+		service := d.Service(symbol)
+		return Description{Kind: "service", Service: &service}, nil
+	case *desc.MethodDescriptor:
+		method := d.Method(symbol)
+		return Description{Kind: "method", Method: &method}, nil
+	case *desc.MessageDescriptor:
+		message := d.Message(symbol)
+		return Description{Kind: "message", Message: &message}, nil
+	case *desc.EnumDescriptor:
+		enum := d.Enum(symbol)
+		return Description{Kind: "enum", Enum: &enum}, nil
+	case *desc.FieldDescriptor:
+		field := d.Field(symbol)
+		return Description{Kind: "field", Field: &field}, nil
+	default:
+		return Description{}, fmt.Errorf("unsupported descriptor type: %T", symbol)
+	}
+}
+
+// Services describes the given services.
+func (d Describer) Services(services []*desc.ServiceDescriptor) ServiceList {
+	list := ServiceList{Services: []Service{}}
+	for _, sd := range services {
+		list.Services = append(list.Services, d.Service(sd))
+	}
+	return list
+}
+
+// Files describes the given files.
+func (d Describer) Files(files []*desc.FileDescriptor) FileList {
+	list := FileList{Files: []File{}}
+	for _, fd := range files {
+		file := File{Name: fd.GetName(), Package: fd.GetPackage(), Imports: []string{}}
+		for _, dep := range fd.GetDependencies() {
+			file.Imports = append(file.Imports, dep.GetName())
+		}
+		list.Files = append(list.Files, file)
+	}
+	return list
+}
+
+func (d Describer) Service(sd *desc.ServiceDescriptor) Service {
+	service := Service{
+		Name:     sd.GetFullyQualifiedName(),
+		File:     sd.GetFile().GetName(),
+		Comments: comments(sd),
+		Options:  d.options(sd),
+		Methods:  []Method{},
+	}
+	for _, md := range sd.GetMethods() {
+		service.Methods = append(service.Methods, d.Method(md))
+	}
+	return service
+}
+
+func (d Describer) Method(md *desc.MethodDescriptor) Method {
+	return Method{
+		Name:       md.GetFullyQualifiedName(),
+		InputType:  md.GetInputType().GetFullyQualifiedName(),
+		OutputType: md.GetOutputType().GetFullyQualifiedName(),
+		Streaming:  StreamingKind(md),
+		Comments:   comments(md),
+		Options:    d.options(md),
+	}
+}
+
+// StreamingKind returns which sides of the method stream, "unary" if none
+// does, "client", "server" or "bidi" if both do.
+func StreamingKind(md *desc.MethodDescriptor) string {
+	switch {
+	case md.IsClientStreaming() && md.IsServerStreaming():
+		return "bidi"
+	case md.IsClientStreaming():
+		return "client"
+	case md.IsServerStreaming():
+		return "server"
+	default:
+		return "unary"
+	}
+}
+
+func (d Describer) Message(md *desc.MessageDescriptor) Message {
+	message := Message{
+		Name:     md.GetFullyQualifiedName(),
+		File:     md.GetFile().GetName(),
+		Comments: comments(md),
+		Options:  d.options(md),
+		Fields:   []Field{},
+	}
+	for _, fd := range md.GetFields() {
+		message.Fields = append(message.Fields, d.Field(fd))
+	}
+	for _, nested := range md.GetNestedMessageTypes() {
+		if nested.IsMapEntry() {
+			continue
+		}
+		message.Messages = append(message.Messages, d.Message(nested))
+	}
+	for _, ed := range md.GetNestedEnumTypes() {
+		message.Enums = append(message.Enums, d.Enum(ed))
+	}
+	return message
+}
+
+// Extensions describes the given extension fields.
+func (d Describer) Extensions(exts []*desc.FieldDescriptor) []Extension {
+	var extensions []Extension
+	for _, ext := range exts {
+		field := d.Field(ext)
+		field.Name = ext.GetFullyQualifiedName()
+		extensions = append(extensions, Extension{Field: field, File: ext.GetFile().GetName()})
+	}
+	return extensions
+}
+
+func (d Describer) Field(fd *desc.FieldDescriptor) Field {
+	label := strings.ToLower(strings.TrimPrefix(fd.GetLabel().String(), "LABEL_"))
+	if fd.IsMap() {
+		label = "map"
+	}
+	field := Field{
+		Name:     fd.GetName(),
+		Number:   fd.GetNumber(),
+		Type:     strings.TrimPrefix(FieldTypeName(fd), "repeated "),
+		Label:    label,
+		JSONName: fd.GetJSONName(),
+		Comments: comments(fd),
+		Options:  d.options(fd),
+	}
+	if oneof := fd.GetOneOf(); oneof != nil && !oneof.IsSynthetic() {
+		field.Oneof = oneof.GetName()
+	}
+	return field
+}
+
+func (d Describer) Enum(ed *desc.EnumDescriptor) Enum {
+	enum := Enum{
+		Name:     ed.GetFullyQualifiedName(),
+		File:     ed.GetFile().GetName(),
+		Comments: comments(ed),
+		Options:  d.options(ed),
+		Values:   []EnumValue{},
+	}
+	for _, vd := range ed.GetValues() {
+		enum.Values = append(enum.Values, EnumValue{
+			Name:     vd.GetName(),
+			Number:   vd.GetNumber(),
+			Comments: comments(vd),
+			Options:  d.options(vd),
+		})
+	}
+	return enum
+}
+
+// options returns the options of d in their JSON representation, or nil if
+// no options are set or they can't be represented.
+func (d Describer) options(dsc desc.Descriptor) map[string]any {
+	// Options are always generated descriptorpb messages.
+	opts, ok := dsc.GetOptions().(proto.Message)
+	if !ok || proto.Size(opts) == 0 {
+		return nil
+	}
+	opts = proto.Clone(opts)
+	if err := ResolveExtensions(opts, d.Resolver); err != nil {
+		return nil
+	}
+	b, err := Marshaler{Resolver: d.Resolver}.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := decodeJSON(b, &m); err != nil || len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// comments returns the leading comments of d, or the trailing comments if
+// there are no leading ones.
+func comments(d desc.Descriptor) string {
+	info := d.GetSourceInfo()
+	c := info.GetLeadingComments()
+	if strings.TrimSpace(c) == "" {
+		c = info.GetTrailingComments()
+	}
+	// Comments usually start with a space after the comment marker.
+	lines := strings.Split(strings.TrimSpace(c), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,98 @@
+package proto
+
+import (
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// OpenAPIVersion is the OpenAPI version of generated documents. OpenAPI 3.1
+// uses JSON Schema draft 2020-12, so the schemas of SchemaGenerator can be used
+// without changes.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIInfo is the metadata of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title   string
+	Version string
+}
+
+// NewOpenAPIDocument returns an OpenAPI document describing the methods of
+// services. Every method is a POST operation on the path /pkg.Service/Method,
+// with request and response bodies in the protojson representation of the
+// input and output type. Comments of services, methods and fields are used as
+// descriptions.
+//
+// As OpenAPI can't describe streams, the bodies of streaming methods describe
+// a single message of the stream, and the operation has the extensions
+// x-ttrpc-streaming, which is one of "client", "server" or "bidi", and
+// x-ttrpc-client-streaming and x-ttrpc-server-streaming.
+func NewOpenAPIDocument(services []*desc.ServiceDescriptor, info OpenAPIInfo) map[string]any {
+	gen := &SchemaGenerator{RefPrefix: "#/components/schemas/"}
+	paths := make(map[string]any)
+	tags := []any{}
+
+	for _, sd := range services {
+		tag := map[string]any{"name": sd.GetFullyQualifiedName()}
+		if c := comments(sd); c != "" {
+			tag["description"] = c
+		}
+		tags = append(tags, tag)
+
+		for _, md := range sd.GetMethods() {
+			paths["/"+sd.GetFullyQualifiedName()+"/"+md.GetName()] = map[string]any{
+				"post": openAPIOperation(gen, md),
+			}
+		}
+	}
+
+	schemas := gen.Definitions()
+	if schemas == nil {
+		schemas = make(map[string]any)
+	}
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":   info.Title,
+			"version": info.Version,
+		},
+		"tags":       tags,
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func openAPIOperation(gen *SchemaGenerator, md *desc.MethodDescriptor) map[string]any {
+	op := map[string]any{
+		"operationId": md.GetFullyQualifiedName(),
+		"tags":        []any{md.GetService().GetFullyQualifiedName()},
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  openAPIContent(gen, md.GetInputType()),
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Successful response.",
+				"content":     openAPIContent(gen, md.GetOutputType()),
+			},
+		},
+	}
+	if c := comments(md); c != "" {
+		summary, _, _ := strings.Cut(c, "\n")
+		op["summary"] = summary
+		op["description"] = c
+	}
+
+	if md.IsClientStreaming() || md.IsServerStreaming() {
+		op["x-ttrpc-streaming"] = StreamingKind(md)
+		op["x-ttrpc-client-streaming"] = md.IsClientStreaming()
+		op["x-ttrpc-server-streaming"] = md.IsServerStreaming()
+	}
+	return op
+}
+
+func openAPIContent(gen *SchemaGenerator, md *desc.MessageDescriptor) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": gen.Ref(md)},
+	}
+}
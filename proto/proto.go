@@ -1,169 +1,97 @@
 package proto
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"path/filepath"
+	"os"
 	"sort"
+	"strings"
 
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/desc/protoprint"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
-type Source struct {
-	fileDescs         []*desc.FileDescriptor
-	includedFileDescs []*desc.FileDescriptor
-}
-
-func NewSource(files, includedFiles []*desc.FileDescriptor) *Source {
-	return &Source{
-		fileDescs:         files,
-		includedFileDescs: includedFiles,
-	}
-}
-
-func (s *Source) GetServices() []*desc.ServiceDescriptor {
-	services := make(map[string]*desc.ServiceDescriptor, 0)
-
-	// Included files first, so that they can be overridden.
-	for _, fileDesc := range s.includedFileDescs {
-		for _, service := range fileDesc.GetServices() {
-			services[service.GetFullyQualifiedName()] = service
-		}
-	}
-	for _, fileDesc := range s.fileDescs {
-		for _, service := range fileDesc.GetServices() {
-			services[service.GetFullyQualifiedName()] = service
-		}
-	}
-
-	return mapToSortedSlice(services)
-}
-
-func (s *Source) GetMessages() []*desc.MessageDescriptor {
-	messages := make(map[string]*desc.MessageDescriptor, 0)
-
-	// Included files first, so that they can be overridden.
-	for _, fileDesc := range s.includedFileDescs {
-		for _, message := range fileDesc.GetMessageTypes() {
-			messages[message.GetFullyQualifiedName()] = message
-		}
-	}
-	for _, fileDesc := range s.fileDescs {
-		for _, message := range fileDesc.GetMessageTypes() {
-			messages[message.GetFullyQualifiedName()] = message
-		}
-	}
-
-	return mapToSortedSlice(messages)
-}
-
-func (s *Source) FindSymbol(symbol string) (desc.Descriptor, error) {
-	// User-defined symbols first, so the are chosen over built-in symbols.
-	for _, fileDesc := range s.fileDescs {
-		if symbol := fileDesc.FindSymbol(symbol); symbol != nil {
-			return symbol, nil
-		}
-	}
-	for _, fileDesc := range s.includedFileDescs {
-		if symbol := fileDesc.FindSymbol(symbol); symbol != nil {
-			return symbol, nil
-		}
-	}
-
-	return nil, fmt.Errorf("symbol %s not found", symbol)
+type Parser struct {
+	parser protoparse.Parser
+	roots  []importRoot
 }
 
-func (s *Source) FindMethod(method string) (*desc.MethodDescriptor, error) {
-	symbol, err := s.FindSymbol(method)
-	if err != nil {
-		return nil, err
-	}
-	methodDesc, ok := symbol.(*desc.MethodDescriptor)
-	if !ok {
-		return nil, fmt.Errorf("symbol %s is not a method", method)
+func NewParser() *Parser {
+	p := &Parser{
+		parser: protoparse.Parser{
+			IncludeSourceCodeInfo: true,
+		},
 	}
-	return methodDesc, nil
+	p.parser.Accessor = p.open
+	return p
 }
 
-func (s *Source) FindService(service string) (*desc.ServiceDescriptor, error) {
-	symbol, err := s.FindSymbol(service)
+// AddImportPath adds a location imports and proto files are resolved from.
+// See OpenImportPath for the supported kinds of locations. Import paths are
+// searched in the order they are added, before falling back to the local
+// file system. It returns the names of all proto files in the location.
+func (p *Parser) AddImportPath(ref string) ([]string, error) {
+	fsys, dir, err := OpenImportPath(ref)
 	if err != nil {
 		return nil, err
 	}
-	serviceDesc, ok := symbol.(*desc.ServiceDescriptor)
-	if !ok {
-		return nil, fmt.Errorf("symbol %s is not a service", service)
-	}
-	return serviceDesc, nil
-}
-
-func (s *Source) FindMessage(message string) (*desc.MessageDescriptor, error) {
-	symbol, err := s.FindSymbol(message)
+	filenames, err := FindProtoFiles(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
-	messageDesc, ok := symbol.(*desc.MessageDescriptor)
-	if !ok {
-		return nil, fmt.Errorf("symbol %s is not a message", message)
-	}
-	return messageDesc, nil
-}
-
-func (s *Source) ListServices() ([]string, error) {
-	panic("not implemented")
-}
-
-func (s *Source) AllExtensionsForType(_ string) ([]*desc.FieldDescriptor, error) {
-	panic("not implemented")
-}
-
-type Parser struct {
-	parser protoparse.Parser
-}
-
-func NewParser() *Parser {
-	return &Parser{
-		parser: protoparse.Parser{
-			// ImportPaths:           importPaths,
-			// InferImportPaths:      len(importPaths) == 0,
-			IncludeSourceCodeInfo: true,
-		},
-	}
+	p.roots = append(p.roots, importRoot{ref: ref, fsys: fsys})
+	return filenames, nil
 }
 
 func (p *Parser) ParseFiles(filenames ...string) ([]*desc.FileDescriptor, error) {
 	return p.parser.ParseFiles(filenames...)
 }
 
+// WalkAndParse parses all proto files in path and its subdirectories. Files
+// are named relative to path, which is also used to resolve imports first.
 func (p *Parser) WalkAndParse(fsys fs.FS, path string) ([]*desc.FileDescriptor, error) {
-	entries, err := fs.ReadDir(fsys, path)
+	subFS, err := fs.Sub(fsys, path)
 	if err != nil {
-		return nil, fmt.Errorf("reading directory: %w", err)
+		return nil, fmt.Errorf("opening directory: %w", err)
 	}
-
-	var filenames []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		filenames = append(filenames, entry.Name())
+	filenames, err := FindProtoFiles(subFS, ".")
+	if err != nil {
+		return nil, err
 	}
 
+	accessor := p.parser.Accessor
 	p.parser.Accessor = func(filename string) (io.ReadCloser, error) {
-		return fsys.Open(filepath.Join(path, filename))
+		if r, err := openFS(subFS, filename); !errors.Is(err, fs.ErrNotExist) {
+			return r, err
+		}
+		return accessor(filename)
 	}
-	defer func() { p.parser.Accessor = nil }()
+	defer func() { p.parser.Accessor = accessor }()
 
 	return p.parser.ParseFiles(filenames...)
 }
 
+// open opens the file with the given name from the import paths or the local
+// file system.
+func (p *Parser) open(filename string) (io.ReadCloser, error) {
+	for _, root := range p.roots {
+		if r, err := openFS(root.fsys, filename); !errors.Is(err, fs.ErrNotExist) {
+			return r, err
+		}
+	}
+	return os.Open(filename)
+}
+
 type Printer struct {
 	printer protoprint.Printer
 }
@@ -185,35 +113,211 @@ func (p *Printer) PrintProtoToString(desc desc.Descriptor) (string, error) {
 
 type Marshaler struct {
 	Multiline bool
+	// Format is the output format, either "json" (default), "yaml", "text"
+	// or "binary" for the protobuf wire format. YAML uses the same mapping
+	// as JSON.
+	Format string
+	// Resolver is used to resolve extensions and google.protobuf.Any
+	// messages. If nil, the global registry is used.
+	Resolver Resolver
+	// AnnotateUnknownFields includes fields unknown to the descriptor of a
+	// message in the output. In JSON, they are listed under the
+	// UnknownFieldsKey of the message.
+	AnnotateUnknownFields bool
 }
 
 func (m Marshaler) Marshal(mes protoreflect.ProtoMessage) ([]byte, error) {
-	b, err := protojson.Marshal(mes)
+	switch m.Format {
+	case "text":
+		return m.marshalText(mes)
+	case "binary":
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(mes)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling proto message: %w", err)
+		}
+		return b, nil
+	}
+
+	resolver := m.resolver()
+	opts := protojson.MarshalOptions{Resolver: resolver}
+	b, err := marshalJSONWithAnys(opts, resolver, mes)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling proto message: %w", err)
 	}
+	if m.AnnotateUnknownFields {
+		if b, err = annotateUnknownFields(b, mes); err != nil {
+			return nil, fmt.Errorf("annotating unknown fields: %w", err)
+		}
+	}
+	if m.Format == "yaml" {
+		if b, err = jsonToYAML(b); err != nil {
+			return nil, fmt.Errorf("converting json to yaml: %w", err)
+		}
+		return b, nil
+	}
 
 	if m.Multiline {
 		// The protojson package viciously adds random spaces between name and value
 		// of JSON multiline output. As this is neither wanted for our users, nor in
-		// the tests, we always use the protojson default marshaling and remarshal
-		// with the standard library to get a clean output.
+		// the tests, we always use the protojson default marshaling and indent it
+		// with the standard library to get a clean output. Unlike remarshaling,
+		// indenting keeps the field order and doesn't escape HTML characters.
 		//
 		// See https://github.com/protocolbuffers/protobuf-go/blob/55f120eb3b91659cee86adeed925c825686556b0/internal/encoding/json/encode.go#L238-L243
 		// for the gory details.
 
-		var intermed any
-		if err := json.Unmarshal(b, &intermed); err != nil {
-			return nil, fmt.Errorf("unmarshaling json: %w", err)
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err != nil {
+			return nil, fmt.Errorf("indenting json: %w", err)
+		}
+		b = buf.Bytes()
+	}
+
+	return b, nil
+}
+
+func (m Marshaler) marshalText(mes protoreflect.ProtoMessage) ([]byte, error) {
+	opts := prototext.MarshalOptions{Multiline: m.Multiline, Resolver: m.resolver(), EmitUnknown: m.AnnotateUnknownFields}
+	b, err := opts.Marshal(mes)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling proto message: %w", err)
+	}
+	// Like protojson, prototext randomly adds spaces to its output.
+	return normalizeText(bytes.TrimSpace(b)), nil
+}
+
+// normalizeText removes the extra spaces prototext randomly inserts between
+// fields and after field names. Indentation and string literals are kept.
+func normalizeText(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	var quote byte
+	escaped, lineStart := false, true
+	for i, c := range b {
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' && !lineStart && i > 0 && b[i-1] == ' ':
+			continue
 		}
+		lineStart = c == '\n' || (lineStart && c == ' ')
+		out = append(out, c)
+	}
+	return out
+}
+
+func (m Marshaler) resolver() Resolver {
+	if m.Resolver == nil {
+		return protoregistry.GlobalTypes
+	}
+	return m.Resolver
+}
+
+type Unmarshaler struct {
+	// Format is the input format, either "json" (default), "yaml", "text"
+	// or "binary" for the protobuf wire format. YAML uses the same mapping
+	// as JSON.
+	Format string
+	// Resolver is used to resolve extensions and google.protobuf.Any
+	// messages. If nil, the global registry is used.
+	Resolver Resolver
+	// AllowUnknownFields ignores fields of JSON and text input that are
+	// unknown to the descriptor of the message, instead of failing.
+	AllowUnknownFields bool
+}
 
-		b, err = json.MarshalIndent(intermed, "", "  ")
+func (u Unmarshaler) Unmarshal(b []byte, mes protoreflect.ProtoMessage) error {
+	resolver := u.resolver()
+	switch u.Format {
+	case "text":
+		opts := prototext.UnmarshalOptions{Resolver: resolver, DiscardUnknown: u.AllowUnknownFields}
+		if err := opts.Unmarshal(b, mes); err != nil {
+			return fmt.Errorf("unmarshaling text: %w", err)
+		}
+		return nil
+	case "binary":
+		opts := proto.UnmarshalOptions{Resolver: resolver}
+		if err := opts.Unmarshal(b, mes); err != nil {
+			return fmt.Errorf("unmarshaling proto message: %w", err)
+		}
+		return nil
+	case "yaml":
+		jsonBytes, err := yamlToJSON(b)
 		if err != nil {
-			return nil, fmt.Errorf("remarshaling json: %w", err)
+			return fmt.Errorf("unmarshaling yaml: %w", err)
 		}
+		return u.unmarshalJSON(jsonBytes, mes, "yaml")
 	}
+	return u.unmarshalJSON(b, mes, "json")
+}
 
-	return b, nil
+// unmarshalJSON unmarshals JSON, which may have been converted from the
+// given format.
+func (u Unmarshaler) unmarshalJSON(b []byte, mes protoreflect.ProtoMessage, format string) error {
+	resolver := u.resolver()
+	opts := protojson.UnmarshalOptions{Resolver: resolver, DiscardUnknown: u.AllowUnknownFields}
+	if err := unmarshalJSONWithAnys(opts, resolver, b, mes); err != nil {
+		if explained := explainJSONError(mes.ProtoReflect().Descriptor(), b, u.AllowUnknownFields); explained != nil {
+			err = explained
+		}
+		return fmt.Errorf("unmarshaling %s: %w", format, err)
+	}
+	return nil
+}
+
+func (u Unmarshaler) resolver() Resolver {
+	if u.Resolver == nil {
+		return protoregistry.GlobalTypes
+	}
+	return u.Resolver
+}
+
+// ResolveExtensions parses the unknown fields of mes and its nested messages
+// again, so extension fields known to resolver are populated. The ttrpc codec
+// always uses the global registry. If resolver is nil, mes is unchanged.
+func ResolveExtensions(mes protoreflect.ProtoMessage, resolver Resolver) error {
+	if resolver == nil {
+		return nil
+	}
+	b, err := proto.Marshal(mes)
+	if err != nil {
+		return fmt.Errorf("marshaling proto message: %w", err)
+	}
+	proto.Reset(mes)
+	if err := (proto.UnmarshalOptions{Resolver: resolver}).Unmarshal(b, mes); err != nil {
+		return fmt.Errorf("unmarshaling proto message: %w", err)
+	}
+	return nil
+}
+
+// FieldTypeName returns the type of a field as written in a proto file, but
+// with fully-qualified names for message and enum types.
+func FieldTypeName(fd *desc.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", FieldTypeName(fd.GetMapKeyType()), FieldTypeName(fd.GetMapValueType()))
+	}
+
+	var typeName string
+	switch {
+	case fd.GetMessageType() != nil:
+		typeName = fd.GetMessageType().GetFullyQualifiedName()
+	case fd.GetEnumType() != nil:
+		typeName = fd.GetEnumType().GetFullyQualifiedName()
+	default:
+		typeName = strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_"))
+	}
+	if fd.IsRepeated() {
+		return "repeated " + typeName
+	}
+	return typeName
 }
 
 type fullyQualified interface {
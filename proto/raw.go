@@ -0,0 +1,163 @@
+package proto
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RawField is a field of a message decoded without its descriptor, like
+// protoc --decode_raw does.
+type RawField struct {
+	Number protowire.Number `json:"number"`
+	// WireType is one of "varint", "fixed32", "fixed64", "bytes" or "group".
+	WireType string `json:"wireType"`
+	// Value is the guessed value of the field. It is empty for nested
+	// messages and groups.
+	Value string `json:"value,omitempty"`
+	// Fields are the fields of a nested message or group.
+	Fields []RawField `json:"fields,omitempty"`
+}
+
+// DecodeRaw decodes the fields of a message in wire format without knowing
+// its type. Length-delimited fields which can be decoded as message are
+// assumed to be nested messages, other ones are shown as string if they are
+// valid UTF-8 and as bytes otherwise.
+func DecodeRaw(b []byte) ([]RawField, error) {
+	fields, rest, err := decodeRawFields(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("unexpected end group")
+	}
+	return fields, nil
+}
+
+// decodeRawFields decodes fields until the end of b or the end of the group
+// with the given number, and returns the remaining bytes.
+func decodeRawFields(b []byte, group protowire.Number) ([]RawField, []byte, error) {
+	fields := []RawField{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, nil, fmt.Errorf("decoding tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		field := RawField{Number: num}
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+			field.WireType = "varint"
+			field.Value = strconv.FormatUint(v, 10)
+			if int64(v) < 0 {
+				field.Value += fmt.Sprintf(" (int64 %d)", int64(v))
+			}
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+			field.WireType = "fixed32"
+			field.Value = fmt.Sprintf("0x%08x (int32 %d, float %g)", v, int32(v), math.Float32frombits(v))
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+			field.WireType = "fixed64"
+			field.Value = fmt.Sprintf("0x%016x (int64 %d, double %g)", v, int64(v), math.Float64frombits(v))
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+			b = b[n:]
+			field.WireType = "bytes"
+			if nested, ok := guessMessage(v); ok {
+				field.Fields = nested
+			} else if utf8.Valid(v) {
+				field.Value = strconv.Quote(string(v))
+			} else {
+				field.Value = fmt.Sprintf("%x (bytes)", v)
+			}
+		case protowire.StartGroupType:
+			nested, rest, err := decodeRawFields(b, num)
+			if err != nil {
+				return nil, nil, err
+			}
+			b = rest
+			field.WireType = "group"
+			field.Fields = nested
+		case protowire.EndGroupType:
+			if num != group {
+				return nil, nil, fmt.Errorf("unexpected end of group %d", num)
+			}
+			return fields, b, nil
+		default:
+			return nil, nil, fmt.Errorf("field %d: unknown wire type %d", num, typ)
+		}
+		fields = append(fields, field)
+	}
+	if group != 0 {
+		return nil, nil, fmt.Errorf("group %d isn't terminated", group)
+	}
+	return fields, b, nil
+}
+
+// guessMessage tries to decode b as message. Short printable strings are
+// often valid messages by chance, so they are only considered messages if
+// they aren't printable text.
+func guessMessage(b []byte) ([]RawField, bool) {
+	if len(b) == 0 || isPrintable(b) {
+		return nil, false
+	}
+	fields, err := DecodeRaw(b)
+	if err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < ' ' && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatRaw formats decoded fields as tree, with one field per line and
+// nested fields indented.
+func FormatRaw(fields []RawField) string {
+	var sb strings.Builder
+	formatRaw(&sb, fields, "")
+	return sb.String()
+}
+
+func formatRaw(sb *strings.Builder, fields []RawField, indent string) {
+	for _, field := range fields {
+		if field.Value != "" || field.Fields == nil {
+			fmt.Fprintf(sb, "%s%d (%s): %s\n", indent, field.Number, field.WireType, field.Value)
+			continue
+		}
+		fmt.Fprintf(sb, "%s%d (%s) {\n", indent, field.Number, field.WireType)
+		formatRaw(sb, field.Fields, indent+"  ")
+		fmt.Fprintf(sb, "%s}\n", indent)
+	}
+}
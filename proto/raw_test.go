@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeRaw(t *testing.T) {
+	testCases := map[string]struct {
+		b       []byte
+		want    string
+		wantErr bool
+	}{
+		"varint": {
+			b:    protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 150),
+			want: "1 (varint): 150\n",
+		},
+		"negative varint": {
+			b:    protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), ^uint64(0)),
+			want: "1 (varint): 18446744073709551615 (int64 -1)\n",
+		},
+		"fixed32": {
+			b:    protowire.AppendFixed32(protowire.AppendTag(nil, 2, protowire.Fixed32Type), 0x3f800000),
+			want: "2 (fixed32): 0x3f800000 (int32 1065353216, float 1)\n",
+		},
+		"string": {
+			b:    protowire.AppendString(protowire.AppendTag(nil, 3, protowire.BytesType), "foo"),
+			want: "3 (bytes): \"foo\"\n",
+		},
+		"invalid utf-8": {
+			b:    protowire.AppendBytes(protowire.AppendTag(nil, 3, protowire.BytesType), []byte{0xff, 0xfe}),
+			want: "3 (bytes): fffe (bytes)\n",
+		},
+		"nested message": {
+			b: protowire.AppendBytes(protowire.AppendTag(nil, 4, protowire.BytesType),
+				protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1)),
+			want: "4 (bytes) {\n  1 (varint): 1\n}\n",
+		},
+		"group": {
+			b: protowire.AppendTag(
+				protowire.AppendVarint(protowire.AppendTag(
+					protowire.AppendTag(nil, 5, protowire.StartGroupType), 1, protowire.VarintType), 1),
+				5, protowire.EndGroupType),
+			want: "5 (group) {\n  1 (varint): 1\n}\n",
+		},
+		"unterminated group": {
+			b:       protowire.AppendTag(nil, 5, protowire.StartGroupType),
+			wantErr: true,
+		},
+		"truncated": {
+			b:       protowire.AppendTag(nil, 1, protowire.Fixed64Type),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fields, err := DecodeRaw(tc.b)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := FormatRaw(fields); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package proto
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Resolver resolves message and extension types, e.g. for protojson.
+type Resolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// TypeResolver is a Resolver backed by a DescriptorSource. It creates dynamic
+// types for all messages and extensions known to the source. Lookups that
+// don't find a type return protoregistry.NotFound.
+type TypeResolver struct {
+	src DescriptorSource
+
+	mux          sync.Mutex
+	messageTypes map[protoreflect.FullName]protoreflect.MessageType
+	extTypes     map[protoreflect.FullName]protoreflect.ExtensionType
+}
+
+func NewTypeResolver(src DescriptorSource) *TypeResolver {
+	return &TypeResolver{
+		src:          src,
+		messageTypes: make(map[protoreflect.FullName]protoreflect.MessageType),
+		extTypes:     make(map[protoreflect.FullName]protoreflect.ExtensionType),
+	}
+}
+
+// FindMessageByName finds the message type in the source. Well-known types,
+// which might not be part of the source, are resolved using the global
+// registry as fallback.
+func (r *TypeResolver) FindMessageByName(message protoreflect.FullName) (protoreflect.MessageType, error) {
+	symbol, err := r.src.FindSymbol(string(message))
+	if err != nil {
+		return protoregistry.GlobalTypes.FindMessageByName(message)
+	}
+	msgDesc, ok := symbol.(*desc.MessageDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return r.messageType(msgDesc), nil
+}
+
+// FindMessageByURL resolves the message type of a type URL. Everything up to
+// the last '/' of the URL is ignored, so next to the common
+// 'type.googleapis.com/pkg.Message' form, plain message names as used for
+// gogo types by containerd's typeurl package are supported. Types that are
+// registered as JSON with containerd's typeurl package, like
+// 'types.containerd.io/opencontainers/runtime-spec/1/Spec', can't be
+// resolved.
+func (r *TypeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if isContainerdJSONTypeURL(url) {
+		return nil, protoregistry.NotFound
+	}
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+func (r *TypeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	symbol, err := r.src.FindSymbol(string(field))
+	if err != nil {
+		return nil, protoregistry.NotFound
+	}
+	fieldDesc, ok := symbol.(*desc.FieldDescriptor)
+	if !ok || !fieldDesc.IsExtension() {
+		return nil, protoregistry.NotFound
+	}
+	return r.extensionType(fieldDesc), nil
+}
+
+func (r *TypeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	exts, err := r.src.AllExtensionsForType(string(message))
+	if err != nil {
+		return nil, protoregistry.NotFound
+	}
+	for _, ext := range exts {
+		if ext.GetNumber() == int32(field) {
+			return r.extensionType(ext), nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// messageType returns the message type of msg. Types are cached, so every
+// message is always represented by the same type.
+func (r *TypeResolver) messageType(msg *desc.MessageDescriptor) protoreflect.MessageType {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	name := protoreflect.FullName(msg.GetFullyQualifiedName())
+	if mt, ok := r.messageTypes[name]; ok {
+		return mt
+	}
+	mt := dynamicpb.NewMessageType(msg.UnwrapMessage())
+	r.messageTypes[name] = mt
+	return mt
+}
+
+// extensionType returns the extension type of ext. Types are cached, so every
+// extension is always represented by the same type.
+func (r *TypeResolver) extensionType(ext *desc.FieldDescriptor) protoreflect.ExtensionType {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	name := protoreflect.FullName(ext.GetFullyQualifiedName())
+	if xt, ok := r.extTypes[name]; ok {
+		return xt
+	}
+	xt := dynamicpb.NewExtensionType(ext.UnwrapField())
+	r.extTypes[name] = xt
+	return xt
+}
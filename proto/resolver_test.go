@@ -0,0 +1,88 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestTypeResolverExactNames(t *testing.T) {
+	src := NewSource(mustFSSource(t, `
+import "google/protobuf/descriptor.proto";
+message Spec {}
+extend google.protobuf.FieldOptions { string nickname = 50000; }
+`))
+	resolver := NewTypeResolver(src)
+
+	testCases := map[string]struct {
+		find     func() error
+		wantFind bool
+	}{
+		"message by full name": {
+			find:     func() error { _, err := resolver.FindMessageByURL("type.googleapis.com/test.Spec"); return err },
+			wantFind: true,
+		},
+		"message by partial name": {
+			find: func() error { _, err := resolver.FindMessageByURL("type.googleapis.com/Spec"); return err },
+		},
+		"message by close name": {
+			find: func() error { _, err := resolver.FindMessageByName("test.Spc"); return err },
+		},
+		"extension by full name": {
+			find:     func() error { _, err := resolver.FindExtensionByName("test.nickname"); return err },
+			wantFind: true,
+		},
+		"extension by partial name": {
+			find: func() error { _, err := resolver.FindExtensionByName("nickname"); return err },
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.find()
+			if tc.wantFind && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.wantFind && !errors.Is(err, protoregistry.NotFound) {
+				t.Fatalf("expected NotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTypeResolverCachesMessageTypes(t *testing.T) {
+	resolver := NewTypeResolver(mustFSSource(t, `message Spec {}`))
+
+	first, err := resolver.FindMessageByName("test.Spec")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := resolver.FindMessageByName("test.Spec")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Fatalf("expected the same message type for repeated lookups")
+	}
+}
+
+func TestTypeResolverExtensionByNumberNotFound(t *testing.T) {
+	resolver := NewTypeResolver(failingSource{mustFSSource(t, `message Spec {}`)})
+
+	_, err := resolver.FindExtensionByNumber("test.Spec", 100)
+	if !errors.Is(err, protoregistry.NotFound) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+// failingSource is a DescriptorSource that fails to list extensions.
+type failingSource struct {
+	DescriptorSource
+}
+
+func (failingSource) AllExtensionsForType(typeName string) ([]*desc.FieldDescriptor, error) {
+	return nil, fmt.Errorf("no extensions of %s", typeName)
+}
@@ -0,0 +1,382 @@
+package proto
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorSource is a source of protobuf descriptors.
+//
+// The first three methods match grpcurl.DescriptorSource, so every
+// DescriptorSource can be passed to grpcurl directly.
+type DescriptorSource interface {
+	// ListServices returns the sorted fully-qualified names of the services
+	// defined in the root files.
+	ListServices() ([]string, error)
+	// FindSymbol returns the descriptor for the given fully-qualified symbol name.
+	FindSymbol(fullyQualifiedName string) (desc.Descriptor, error)
+	// AllExtensionsForType returns all known extension fields that extend the
+	// message type with the given fully-qualified name.
+	AllExtensionsForType(typeName string) ([]*desc.FieldDescriptor, error)
+	// Files returns all files known to the source, including dependencies.
+	Files() []*desc.FileDescriptor
+	// RootFiles returns the files the source was created from, without the
+	// files they only depend on.
+	RootFiles() []*desc.FileDescriptor
+}
+
+// Source provides typed lookups on top of a DescriptorSource.
+type Source struct {
+	DescriptorSource
+}
+
+func NewSource(src DescriptorSource) *Source {
+	return &Source{DescriptorSource: src}
+}
+
+// GetServices returns the services defined in the root files of the source.
+// Services of files that are only imported aren't included.
+func (s *Source) GetServices() []*desc.ServiceDescriptor {
+	services := make(map[string]*desc.ServiceDescriptor, 0)
+
+	// Files are ordered by precedence, so the first definition wins.
+	for _, fileDesc := range s.RootFiles() {
+		for _, service := range fileDesc.GetServices() {
+			if _, ok := services[service.GetFullyQualifiedName()]; !ok {
+				services[service.GetFullyQualifiedName()] = service
+			}
+		}
+	}
+
+	return mapToSortedSlice(services)
+}
+
+// GetMessages returns the top-level messages defined in the root files of the
+// source.
+func (s *Source) GetMessages() []*desc.MessageDescriptor {
+	messages := make(map[string]*desc.MessageDescriptor, 0)
+
+	// Files are ordered by precedence, so the first definition wins.
+	for _, fileDesc := range s.RootFiles() {
+		for _, message := range fileDesc.GetMessageTypes() {
+			if _, ok := messages[message.GetFullyQualifiedName()]; !ok {
+				messages[message.GetFullyQualifiedName()] = message
+			}
+		}
+	}
+
+	return mapToSortedSlice(messages)
+}
+
+func (s *Source) FindMethod(method string) (*desc.MethodDescriptor, error) {
+	symbol, err := s.lookupSymbol(method, func(d desc.Descriptor) bool {
+		_, ok := d.(*desc.MethodDescriptor)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	methodDesc, ok := symbol.(*desc.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is not a method", method)
+	}
+	return methodDesc, nil
+}
+
+func (s *Source) FindService(service string) (*desc.ServiceDescriptor, error) {
+	symbol, err := s.lookupSymbol(service, func(d desc.Descriptor) bool {
+		_, ok := d.(*desc.ServiceDescriptor)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	serviceDesc, ok := symbol.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is not a service", service)
+	}
+	return serviceDesc, nil
+}
+
+func (s *Source) FindMessage(message string) (*desc.MessageDescriptor, error) {
+	symbol, err := s.lookupSymbol(message, func(d desc.Descriptor) bool {
+		_, ok := d.(*desc.MessageDescriptor)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	messageDesc, ok := symbol.(*desc.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is not a message", message)
+	}
+	return messageDesc, nil
+}
+
+// fileSource is a DescriptorSource backed by already linked file descriptors.
+type fileSource struct {
+	roots []*desc.FileDescriptor
+	files []*desc.FileDescriptor
+}
+
+// NewFileSource returns a DescriptorSource for the given files and all of
+// their transitive dependencies.
+func NewFileSource(files ...*desc.FileDescriptor) DescriptorSource {
+	var all []*desc.FileDescriptor
+	seen := make(map[string]struct{})
+	var add func(fds []*desc.FileDescriptor) []*desc.FileDescriptor
+	add = func(fds []*desc.FileDescriptor) []*desc.FileDescriptor {
+		var added []*desc.FileDescriptor
+		for _, fd := range fds {
+			if _, ok := seen[fd.GetName()]; ok {
+				continue
+			}
+			seen[fd.GetName()] = struct{}{}
+			all = append(all, fd)
+			added = append(added, fd)
+		}
+		for _, fd := range added {
+			add(fd.GetDependencies())
+		}
+		return added
+	}
+	roots := add(files)
+
+	return &fileSource{roots: roots, files: all}
+}
+
+// NewProtosetSource returns a DescriptorSource for the given files, whose
+// contents are encoded FileDescriptorSet protos.
+func NewProtosetSource(filenames ...string) (DescriptorSource, error) {
+	var sets []*descriptorpb.FileDescriptorSet
+	for _, filename := range filenames {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading protoset: %w", err)
+		}
+		set, err := UnmarshalDescriptorSet(b)
+		if err != nil {
+			return nil, fmt.Errorf("protoset %s: %w", filename, err)
+		}
+		sets = append(sets, set)
+	}
+	return NewDescriptorSetSource(sets...)
+}
+
+// NewDescriptorSetSource returns a DescriptorSource for the files contained
+// in the given descriptor sets.
+func NewDescriptorSetSource(sets ...*descriptorpb.FileDescriptorSet) (DescriptorSource, error) {
+	merged := &descriptorpb.FileDescriptorSet{}
+	var names []string
+	seen := make(map[string]struct{})
+	for _, set := range sets {
+		for _, fdp := range set.GetFile() {
+			if _, ok := seen[fdp.GetName()]; ok {
+				continue
+			}
+			seen[fdp.GetName()] = struct{}{}
+			merged.File = append(merged.File, fdp)
+			names = append(names, fdp.GetName())
+		}
+	}
+
+	if len(merged.File) == 0 {
+		return NewFileSource(), nil
+	}
+
+	fileDescs, err := desc.CreateFileDescriptorsFromSet(merged)
+	if err != nil {
+		return nil, fmt.Errorf("creating file descriptors from set: %w", err)
+	}
+
+	files := make([]*desc.FileDescriptor, 0, len(names))
+	for _, name := range names {
+		files = append(files, fileDescs[name])
+	}
+	return NewFileSource(files...), nil
+}
+
+// MarshalDescriptorSet encodes the given files and all of their dependencies
+// as FileDescriptorSet.
+func MarshalDescriptorSet(files ...*desc.FileDescriptor) ([]byte, error) {
+	b, err := proto.Marshal(desc.ToFileDescriptorSet(files...))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling descriptor set: %w", err)
+	}
+	return b, nil
+}
+
+// UnmarshalDescriptorSet decodes an encoded FileDescriptorSet.
+func UnmarshalDescriptorSet(b []byte) (*descriptorpb.FileDescriptorSet, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("unmarshaling descriptor set: %w", err)
+	}
+	return set, nil
+}
+
+// NewFSSource parses all proto files in the directory dir of fsys and returns
+// a DescriptorSource for them. It is used for the embedded include files.
+func NewFSSource(parser *Parser, fsys fs.FS, dir string) (DescriptorSource, error) {
+	files, err := parser.WalkAndParse(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileSource(files...), nil
+}
+
+func (s *fileSource) ListServices() ([]string, error) {
+	var services []string
+	seen := make(map[string]struct{})
+	for _, fileDesc := range s.roots {
+		for _, service := range fileDesc.GetServices() {
+			name := service.GetFullyQualifiedName()
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (s *fileSource) FindSymbol(symbol string) (desc.Descriptor, error) {
+	for _, fileDesc := range s.files {
+		if symbol := fileDesc.FindSymbol(symbol); symbol != nil {
+			return symbol, nil
+		}
+	}
+	return nil, fmt.Errorf("symbol %s not found", symbol)
+}
+
+func (s *fileSource) AllExtensionsForType(typeName string) ([]*desc.FieldDescriptor, error) {
+	var exts []*desc.FieldDescriptor
+	for _, fileDesc := range s.files {
+		for _, ext := range fileExtensions(fileDesc) {
+			if ext.GetOwner().GetFullyQualifiedName() == typeName {
+				exts = append(exts, ext)
+			}
+		}
+	}
+	return exts, nil
+}
+
+func (s *fileSource) Files() []*desc.FileDescriptor {
+	return s.files
+}
+
+func (s *fileSource) RootFiles() []*desc.FileDescriptor {
+	return s.roots
+}
+
+// compositeSource combines multiple sources. Sources earlier in the list take
+// precedence over later ones.
+type compositeSource struct {
+	sources []DescriptorSource
+}
+
+// NewCompositeSource returns a DescriptorSource that combines the given sources.
+// When a symbol is defined by more than one source, the definition of the
+// source that comes first wins.
+func NewCompositeSource(sources ...DescriptorSource) DescriptorSource {
+	return &compositeSource{sources: sources}
+}
+
+func (s *compositeSource) ListServices() ([]string, error) {
+	var services []string
+	seen := make(map[string]struct{})
+	for _, src := range s.sources {
+		srcServices, err := src.ListServices()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range srcServices {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (s *compositeSource) FindSymbol(symbol string) (desc.Descriptor, error) {
+	for _, src := range s.sources {
+		if d, err := src.FindSymbol(symbol); err == nil {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("symbol %s not found", symbol)
+}
+
+func (s *compositeSource) AllExtensionsForType(typeName string) ([]*desc.FieldDescriptor, error) {
+	var exts []*desc.FieldDescriptor
+	seen := make(map[int32]struct{})
+	for _, src := range s.sources {
+		srcExts, err := src.AllExtensionsForType(typeName)
+		if err != nil {
+			return nil, err
+		}
+		for _, ext := range srcExts {
+			if _, ok := seen[ext.GetNumber()]; ok {
+				continue
+			}
+			seen[ext.GetNumber()] = struct{}{}
+			exts = append(exts, ext)
+		}
+	}
+	return exts, nil
+}
+
+func (s *compositeSource) Files() []*desc.FileDescriptor {
+	var files []*desc.FileDescriptor
+	seen := make(map[string]struct{})
+	for _, src := range s.sources {
+		for _, fileDesc := range src.Files() {
+			if _, ok := seen[fileDesc.GetName()]; ok {
+				continue
+			}
+			seen[fileDesc.GetName()] = struct{}{}
+			files = append(files, fileDesc)
+		}
+	}
+	return files
+}
+
+func (s *compositeSource) RootFiles() []*desc.FileDescriptor {
+	var files []*desc.FileDescriptor
+	seen := make(map[string]struct{})
+	for _, src := range s.sources {
+		for _, fileDesc := range src.RootFiles() {
+			if _, ok := seen[fileDesc.GetName()]; ok {
+				continue
+			}
+			seen[fileDesc.GetName()] = struct{}{}
+			files = append(files, fileDesc)
+		}
+	}
+	return files
+}
+
+func fileExtensions(fileDesc *desc.FileDescriptor) []*desc.FieldDescriptor {
+	exts := append([]*desc.FieldDescriptor(nil), fileDesc.GetExtensions()...)
+	var walk func(msgs []*desc.MessageDescriptor)
+	walk = func(msgs []*desc.MessageDescriptor) {
+		for _, msg := range msgs {
+			exts = append(exts, msg.GetNestedExtensions()...)
+			walk(msg.GetNestedMessageTypes())
+		}
+	}
+	walk(fileDesc.GetMessageTypes())
+	return exts
+}
@@ -0,0 +1,163 @@
+package proto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// RecordSeparator separates messages in a stream of text format messages.
+const RecordSeparator = 0x1E
+
+// MessageReader reads a stream of messages in the format of its Unmarshaler.
+// JSON messages are concatenated values, optionally separated by whitespace.
+// YAML messages are the non-empty documents of a multi-document stream. Text
+// format messages are separated by RecordSeparator. Binary messages are
+// length-delimited, each prefixed with its size as varint.
+type MessageReader struct {
+	unmarshaler Unmarshaler
+	r           *bufio.Reader
+	dec         *json.Decoder
+	yamlDec     *yaml.Decoder
+	done        bool
+	count       int
+}
+
+// NewMessageReader returns a MessageReader reading from r.
+func NewMessageReader(r io.Reader, u Unmarshaler) *MessageReader {
+	mr := &MessageReader{unmarshaler: u, r: bufio.NewReader(r)}
+	switch u.Format {
+	case "text", "binary":
+	case "yaml":
+		mr.yamlDec = yaml.NewDecoder(mr.r)
+	default:
+		mr.dec = json.NewDecoder(mr.r)
+	}
+	return mr
+}
+
+// Next reads the next message of the stream into mes. It returns io.EOF if
+// there are no more messages.
+func (r *MessageReader) Next(mes protoreflect.ProtoMessage) error {
+	var err error
+	switch r.unmarshaler.Format {
+	case "text":
+		err = r.nextText(mes)
+	case "binary":
+		opts := protodelim.UnmarshalOptions{
+			UnmarshalOptions: proto.UnmarshalOptions{Resolver: r.unmarshaler.resolver()},
+			MaxSize:          -1,
+		}
+		err = opts.UnmarshalFrom(r.r, mes)
+		if err != nil && !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("unmarshaling message %d: %w", r.count+1, err)
+		}
+	case "yaml":
+		err = r.nextYAML(mes)
+	default:
+		err = r.nextJSON(mes)
+	}
+	if err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+func (r *MessageReader) nextJSON(mes protoreflect.ProtoMessage) error {
+	var raw json.RawMessage
+	if err := r.dec.Decode(&raw); errors.Is(err, io.EOF) {
+		return io.EOF
+	} else if err != nil {
+		return fmt.Errorf("reading message %d: %w", r.count+1, err)
+	}
+	return r.unmarshaler.Unmarshal(raw, mes)
+}
+
+func (r *MessageReader) nextYAML(mes protoreflect.ProtoMessage) error {
+	// Empty documents, e.g. after a trailing document marker, are skipped.
+	// Empty messages are written as '{}'.
+	var node yaml.Node
+	for isEmptyYAMLDocument(&node) {
+		if err := r.yamlDec.Decode(&node); errors.Is(err, io.EOF) {
+			return io.EOF
+		} else if err != nil {
+			return fmt.Errorf("reading message %d: %w", r.count+1, err)
+		}
+	}
+	b, err := yamlNodeToJSON(&node)
+	if err != nil {
+		return fmt.Errorf("reading message %d: %w", r.count+1, err)
+	}
+	return r.unmarshaler.unmarshalJSON(b, mes, "yaml")
+}
+
+func (r *MessageReader) nextText(mes protoreflect.ProtoMessage) error {
+	if r.done {
+		return io.EOF
+	}
+	chunk, err := r.r.ReadBytes(RecordSeparator)
+	if errors.Is(err, io.EOF) {
+		r.done = true
+		// An empty stream has no messages, but a stream ending with a record
+		// separator has a final, blank message.
+		if r.count == 0 && len(bytes.TrimSpace(chunk)) == 0 {
+			return io.EOF
+		}
+	} else if err != nil {
+		return fmt.Errorf("reading message %d: %w", r.count+1, err)
+	}
+	return r.unmarshaler.Unmarshal(bytes.TrimSuffix(chunk, []byte{RecordSeparator}), mes)
+}
+
+// MessageWriter writes a stream of messages in the format of its Marshaler,
+// which can be read by a MessageReader. JSON, YAML and text format messages
+// are terminated by a newline, YAML messages are separated by a document
+// marker and text format messages by RecordSeparator.
+type MessageWriter struct {
+	marshaler Marshaler
+	w         io.Writer
+	count     int
+}
+
+// NewMessageWriter returns a MessageWriter writing to w.
+func NewMessageWriter(w io.Writer, m Marshaler) *MessageWriter {
+	return &MessageWriter{marshaler: m, w: w}
+}
+
+// Write writes mes to the stream.
+func (w *MessageWriter) Write(mes protoreflect.ProtoMessage) error {
+	if w.marshaler.Format == "binary" {
+		if _, err := (protodelim.MarshalOptions{MarshalOptions: proto.MarshalOptions{Deterministic: true}}).MarshalTo(w.w, mes); err != nil {
+			return fmt.Errorf("writing message %d: %w", w.count+1, err)
+		}
+		w.count++
+		return nil
+	}
+
+	b, err := w.marshaler.Marshal(mes)
+	if err != nil {
+		return err
+	}
+	if w.count > 0 {
+		switch w.marshaler.Format {
+		case "text":
+			b = append([]byte{RecordSeparator, '\n'}, b...)
+		case "yaml":
+			b = append([]byte("---\n"), b...)
+		}
+	}
+	if _, err := w.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing message %d: %w", w.count+1, err)
+	}
+	w.count++
+	return nil
+}
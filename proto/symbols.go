@@ -0,0 +1,194 @@
+package proto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// maxSuggestions is the maximum number of close matches suggested when
+// a symbol isn't found.
+const maxSuggestions = 5
+
+// LookupSymbol finds a symbol given by the user. Next to fully-qualified
+// names, it accepts names with a leading dot, grpcurl-style names like
+// 'pkg.Service/Method', and names with omitted leading components, like
+// 'Service.Method', as long as they match a single symbol. If no symbol
+// matches, the error lists close matches. Use FindSymbol for exact lookups.
+func (s *Source) LookupSymbol(name string) (desc.Descriptor, error) {
+	return s.lookupSymbol(name, func(desc.Descriptor) bool { return true })
+}
+
+// lookupSymbol is like LookupSymbol, but only symbols for which isKind returns
+// true are considered when resolving partial names and suggesting matches.
+func (s *Source) lookupSymbol(name string, isKind func(desc.Descriptor) bool) (desc.Descriptor, error) {
+	normalized := NormalizeSymbol(name)
+	if symbol, err := s.DescriptorSource.FindSymbol(normalized); err == nil {
+		return symbol, nil
+	}
+
+	symbols := Symbols(s.Files())
+	for fqn, symbol := range symbols {
+		if !isKind(symbol) {
+			delete(symbols, fqn)
+		}
+	}
+
+	var matches []string
+	for fqn := range symbols {
+		if strings.HasSuffix(fqn, "."+normalized) {
+			matches = append(matches, fqn)
+		}
+	}
+	sort.Strings(matches)
+	switch len(matches) {
+	case 0:
+	case 1:
+		return symbols[matches[0]], nil
+	default:
+		return nil, fmt.Errorf("symbol %s is ambiguous, could be %s", name, joinList(matches, "or"))
+	}
+
+	if suggestions := closeMatches(normalized, symbols); len(suggestions) > 0 {
+		return nil, fmt.Errorf("symbol %s not found, did you mean %s?", name, joinList(suggestions, "or"))
+	}
+	return nil, fmt.Errorf("symbol %s not found", name)
+}
+
+// NormalizeSymbol converts a grpcurl-style symbol name to a fully-qualified
+// name, by removing a leading dot and replacing slashes with dots.
+func NormalizeSymbol(name string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(name, "."), "/", ".")
+}
+
+// Symbols returns all symbols defined in the given files by their
+// fully-qualified name. If a symbol is defined more than once, the
+// definition of the first file wins.
+func Symbols(files []*desc.FileDescriptor) map[string]desc.Descriptor {
+	symbols := make(map[string]desc.Descriptor)
+	add := func(d desc.Descriptor) {
+		if _, ok := symbols[d.GetFullyQualifiedName()]; !ok {
+			symbols[d.GetFullyQualifiedName()] = d
+		}
+	}
+	addEnums := func(enums []*desc.EnumDescriptor) {
+		for _, enum := range enums {
+			add(enum)
+			for _, value := range enum.GetValues() {
+				add(value)
+			}
+		}
+	}
+	var addMessages func(msgs []*desc.MessageDescriptor)
+	addMessages = func(msgs []*desc.MessageDescriptor) {
+		for _, msg := range msgs {
+			add(msg)
+			for _, field := range msg.GetFields() {
+				add(field)
+			}
+			for _, ext := range msg.GetNestedExtensions() {
+				add(ext)
+			}
+			addEnums(msg.GetNestedEnumTypes())
+			addMessages(msg.GetNestedMessageTypes())
+		}
+	}
+
+	for _, file := range files {
+		for _, service := range file.GetServices() {
+			add(service)
+			for _, method := range service.GetMethods() {
+				add(method)
+			}
+		}
+		for _, ext := range file.GetExtensions() {
+			add(ext)
+		}
+		addEnums(file.GetEnumTypes())
+		addMessages(file.GetMessageTypes())
+	}
+	return symbols
+}
+
+// closeMatches returns the symbols with the smallest edit distance to name.
+// Names with omitted leading components are compared to the same number of
+// trailing components of each symbol. The allowed distance depends on the
+// length of the last component, so that long package names don't lead to
+// suggestions with entirely different names.
+func closeMatches(name string, symbols map[string]desc.Descriptor) []string {
+	type match struct {
+		fqn      string
+		distance int
+	}
+
+	components := strings.Count(name, ".") + 1
+	maxDistance := len(lastComponents(name, 1))/3 + 1
+	var matches []match
+	for fqn := range symbols {
+		distance := levenshtein(name, fqn)
+		if suffix := lastComponents(fqn, components); suffix != fqn {
+			if d := levenshtein(name, suffix); d < distance {
+				distance = d
+			}
+		}
+		if distance <= maxDistance {
+			matches = append(matches, match{fqn: fqn, distance: distance})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].fqn < matches[j].fqn
+	})
+	var suggestions []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].fqn)
+	}
+	return suggestions
+}
+
+func lastComponents(fqn string, n int) string {
+	parts := strings.Split(fqn, ".")
+	if n >= len(parts) {
+		return fqn
+	}
+	return strings.Join(parts[len(parts)-n:], ".")
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur[j] = prev[j-1]
+			if a[i-1] != b[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// joinList formats items like "a, b or c".
+func joinList(items []string, conj string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conj + " " + items[len(items)-1]
+}
@@ -0,0 +1,323 @@
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// CommentedTemplate returns a template of the message md in the given format,
+// 'json', 'yaml' or 'text'. Every field is preceded by comments with its type and the
+// comments of its definition in the proto files. Fields are set to their
+// default values, repeated and map fields contain a single element and only
+// the first field of each oneof is set.
+//
+// The JSON template contains '//' comments, which must be removed with
+// StripJSONComments before it can be parsed.
+func CommentedTemplate(md *desc.MessageDescriptor, format string) (string, error) {
+	t := templateWriter{visiting: map[string]bool{}}
+	switch format {
+	case "json":
+		t.comment = "// "
+	case "yaml":
+		t.comment, t.yaml = "# ", true
+	case "text":
+		t.comment, t.text = "# ", true
+	default:
+		return "", fmt.Errorf("unsupported format: %q", format)
+	}
+
+	t.writeComments("", md.GetFullyQualifiedName(), comments(md))
+	t.sb.WriteString("\n")
+	switch {
+	case t.yaml:
+		t.visiting[md.GetFullyQualifiedName()] = true
+		t.writeYAMLFields(md, "")
+	case t.text:
+		t.writeFields(md, "")
+	default:
+		t.writeMessage(md, "")
+		t.sb.WriteString("\n")
+	}
+	return t.sb.String(), nil
+}
+
+type templateWriter struct {
+	sb      strings.Builder
+	comment string
+	text    bool
+	yaml    bool
+	// visiting contains the messages currently written, to stop at
+	// recursive types.
+	visiting map[string]bool
+}
+
+func (t *templateWriter) writeComments(indent string, lines ...string) {
+	for _, line := range lines {
+		for _, l := range strings.Split(line, "\n") {
+			if l == "" {
+				continue
+			}
+			t.sb.WriteString(indent + t.comment + l + "\n")
+		}
+	}
+}
+
+// writeMessage writes md as JSON object or text message in braces.
+func (t *templateWriter) writeMessage(md *desc.MessageDescriptor, indent string) {
+	if t.visiting[md.GetFullyQualifiedName()] {
+		t.sb.WriteString("{}")
+		return
+	}
+	t.visiting[md.GetFullyQualifiedName()] = true
+	defer delete(t.visiting, md.GetFullyQualifiedName())
+
+	t.sb.WriteString("{\n")
+	t.writeFields(md, indent+"  ")
+	t.sb.WriteString(indent + "}")
+}
+
+func (t *templateWriter) writeFields(md *desc.MessageDescriptor, indent string) {
+	fields := templateFields(md)
+	for i, fd := range fields {
+		t.writeComments(indent, comments(fd), t.typeComment(fd))
+		if t.text {
+			t.sb.WriteString(indent + fd.GetName())
+			if fd.GetMessageType() != nil {
+				t.sb.WriteString(" ")
+			} else {
+				t.sb.WriteString(": ")
+			}
+		} else {
+			t.sb.WriteString(indent + strconv.Quote(fd.GetJSONName()) + ": ")
+		}
+		t.writeField(fd, indent)
+		if !t.text && i < len(fields)-1 {
+			t.sb.WriteString(",")
+		}
+		t.sb.WriteString("\n")
+	}
+}
+
+// templateFields returns the fields of md that are set in the template, which
+// are all fields except the alternatives of the first field of a oneof.
+func templateFields(md *desc.MessageDescriptor) []*desc.FieldDescriptor {
+	var fields []*desc.FieldDescriptor
+	for _, fd := range md.GetFields() {
+		oneof := fd.GetOneOf()
+		if oneof != nil && !oneof.IsSynthetic() && oneof.GetChoices()[0] != fd {
+			continue
+		}
+		fields = append(fields, fd)
+	}
+	return fields
+}
+
+// typeComment describes the type of fd, the values of enums and the
+// alternatives of oneofs.
+func (t *templateWriter) typeComment(fd *desc.FieldDescriptor) string {
+	typ := FieldTypeName(fd)
+	if ed := fd.GetEnumType(); ed != nil && !fd.IsMap() {
+		var names []string
+		for _, v := range ed.GetValues() {
+			names = append(names, v.GetName())
+		}
+		typ += ": " + strings.Join(names, " | ")
+	}
+	if oneof := fd.GetOneOf(); oneof != nil && !oneof.IsSynthetic() && len(oneof.GetChoices()) > 1 {
+		var alternatives []string
+		for _, choice := range oneof.GetChoices()[1:] {
+			if t.text {
+				alternatives = append(alternatives, choice.GetName())
+			} else {
+				alternatives = append(alternatives, choice.GetJSONName())
+			}
+		}
+		typ += fmt.Sprintf(" (oneof %s, alternatively %s)", oneof.GetName(), joinList(alternatives, "or"))
+	}
+	return typ
+}
+
+func (t *templateWriter) writeField(fd *desc.FieldDescriptor, indent string) {
+	switch {
+	case fd.IsMap():
+		key, value := fd.GetMapKeyType(), fd.GetMapValueType()
+		if t.text {
+			keyValue := mapKeyExample(key)
+			if key.GetType() == descriptorpb.FieldDescriptorProto_TYPE_STRING {
+				keyValue = strconv.Quote(keyValue)
+			}
+			t.sb.WriteString("{\n" + indent + "  key: " + keyValue + "\n")
+			t.sb.WriteString(indent + "  value")
+			if value.GetMessageType() != nil {
+				t.sb.WriteString(" ")
+			} else {
+				t.sb.WriteString(": ")
+			}
+			t.writeValue(value, indent+"  ")
+			t.sb.WriteString("\n" + indent + "}")
+			return
+		}
+		t.sb.WriteString("{" + strconv.Quote(mapKeyExample(key)) + ": ")
+		t.writeValue(value, indent)
+		t.sb.WriteString("}")
+	case fd.IsRepeated() && !t.text:
+		t.sb.WriteString("[")
+		t.writeValue(fd, indent)
+		t.sb.WriteString("]")
+	default:
+		t.writeValue(fd, indent)
+	}
+}
+
+func (t *templateWriter) writeValue(fd *desc.FieldDescriptor, indent string) {
+	md := fd.GetMessageType()
+	if md == nil {
+		t.sb.WriteString(t.scalarValue(fd))
+		return
+	}
+	if !t.text {
+		if v, ok := wellKnownTemplateValue(md); ok {
+			t.sb.WriteString(v)
+			return
+		}
+	}
+	t.writeMessage(md, indent)
+}
+
+func (t *templateWriter) scalarValue(fd *desc.FieldDescriptor) string {
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return "false"
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return `""`
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		name := fd.GetEnumType().GetValues()[0].GetName()
+		if t.text {
+			return name
+		}
+		return strconv.Quote(name)
+	default:
+		return "0"
+	}
+}
+
+// writeYAMLFields writes the fields of md as YAML mapping. Scalars are
+// written like in JSON, which is valid YAML.
+func (t *templateWriter) writeYAMLFields(md *desc.MessageDescriptor, indent string) {
+	for _, fd := range templateFields(md) {
+		t.writeComments(indent, comments(fd), t.typeComment(fd))
+		t.sb.WriteString(indent + fd.GetJSONName() + ":")
+		switch {
+		case fd.IsMap():
+			t.sb.WriteString("\n" + indent + "  " + mapKeyExample(fd.GetMapKeyType()) + ":")
+			t.writeYAMLValue(fd.GetMapValueType(), indent+"    ")
+		case fd.IsRepeated():
+			t.sb.WriteString("\n" + indent + "  -")
+			t.writeYAMLValue(fd, indent+"    ")
+		default:
+			t.writeYAMLValue(fd, indent+"  ")
+		}
+	}
+}
+
+// writeYAMLValue writes the value of fd after its key or list marker, with
+// nested fields at the given indent.
+func (t *templateWriter) writeYAMLValue(fd *desc.FieldDescriptor, indent string) {
+	md := fd.GetMessageType()
+	if md == nil {
+		t.sb.WriteString(" " + t.scalarValue(fd) + "\n")
+		return
+	}
+	if v, ok := wellKnownTemplateValue(md); ok {
+		t.sb.WriteString(" " + v + "\n")
+		return
+	}
+	if t.visiting[md.GetFullyQualifiedName()] || len(md.GetFields()) == 0 {
+		t.sb.WriteString(" {}\n")
+		return
+	}
+	t.visiting[md.GetFullyQualifiedName()] = true
+	defer delete(t.visiting, md.GetFullyQualifiedName())
+
+	t.sb.WriteString("\n")
+	t.writeYAMLFields(md, indent)
+}
+
+// wellKnownTemplateValue returns the JSON template of well-known types with
+// a special JSON representation.
+func wellKnownTemplateValue(md *desc.MessageDescriptor) (string, bool) {
+	switch md.GetFullyQualifiedName() {
+	case "google.protobuf.Timestamp":
+		return `"1970-01-01T00:00:00Z"`, true
+	case "google.protobuf.Duration":
+		return `"0s"`, true
+	case "google.protobuf.FieldMask":
+		return `""`, true
+	case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Empty":
+		return "{}", true
+	case "google.protobuf.Value":
+		return "null", true
+	case "google.protobuf.ListValue":
+		return "[]", true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return "0", true
+	case "google.protobuf.BoolValue":
+		return "false", true
+	case "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return `""`, true
+	default:
+		return "", false
+	}
+}
+
+// mapKeyExample returns a map key for the template. It isn't quoted, as
+// keys are always strings in JSON.
+func mapKeyExample(fd *desc.FieldDescriptor) string {
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return "key"
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return "false"
+	default:
+		return "0"
+	}
+}
+
+// StripJSONComments removes '//' comments from JSON, keeping the line breaks
+// so that positions in error messages still match the original lines.
+func StripJSONComments(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString, escaped := false, false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			if i < len(b) {
+				out = append(out, '\n')
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,20 @@
+package proto
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// mustFSSource returns a source of a single proto3 file in package test with
+// the given content.
+func mustFSSource(t *testing.T, content string) DescriptorSource {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"test.proto": {Data: []byte("syntax = \"proto3\";\npackage test;\n" + content)},
+	}
+	src, err := NewFSSource(NewParser(), fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
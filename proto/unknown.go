@@ -0,0 +1,185 @@
+package proto
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnknownFieldsKey is the key of the annotation listing the unknown fields of
+// a message in its JSON representation.
+const UnknownFieldsKey = "@unknownFields"
+
+// UnknownField is a field of a message that isn't known to the message's
+// descriptor, e.g. because the sender uses a newer version of the proto
+// files.
+type UnknownField struct {
+	// Path is the path of the message in JSON notation, like
+	// 'payload.items[0]'. It is empty for the root message.
+	Path string
+	RawField
+}
+
+// FindUnknownFields returns the unknown fields of m and of all messages
+// nested in it, ordered by path.
+func FindUnknownFields(m protoreflect.Message) ([]UnknownField, error) {
+	var unknown []UnknownField
+	if err := findUnknownFields(m, "", &unknown); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(unknown, func(i, j int) bool { return unknown[i].Path < unknown[j].Path })
+	return unknown, nil
+}
+
+func findUnknownFields(m protoreflect.Message, path string, unknown *[]UnknownField) error {
+	if raw := m.GetUnknown(); len(raw) > 0 {
+		fields, err := DecodeRaw(raw)
+		if err != nil {
+			return fmt.Errorf("decoding unknown fields of %s: %w", describePath(path), err)
+		}
+		for _, field := range fields {
+			*unknown = append(*unknown, UnknownField{Path: path, RawField: field})
+		}
+	}
+
+	var err error
+	rangeMessageFields(m, func(step fieldStep, nested protoreflect.Message) bool {
+		err = findUnknownFields(nested, step.join(path), unknown)
+		return err == nil
+	})
+	return err
+}
+
+// annotateUnknownFields adds the unknown fields of mes and its nested messages
+// to the JSON representation b of mes, using the UnknownFieldsKey.
+func annotateUnknownFields(b []byte, mes protoreflect.ProtoMessage) ([]byte, error) {
+	unknown, err := FindUnknownFields(mes.ProtoReflect())
+	if err != nil || len(unknown) == 0 {
+		return b, err
+	}
+
+	// The document is modified in place, so that the order of the fields and
+	// the escaping of protojson are kept.
+	intermed, err := decodeOrderedJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	annotateMessage(mes.ProtoReflect(), intermed)
+	return marshalOrderedJSON(intermed)
+}
+
+func annotateMessage(m protoreflect.Message, v any) {
+	// Well-known types with a special JSON representation can't be annotated.
+	obj, ok := v.(*jsonObject)
+	if !ok {
+		return
+	}
+	if fields, err := DecodeRaw(m.GetUnknown()); err == nil && len(fields) > 0 {
+		obj.set(UnknownFieldsKey, fields)
+	}
+	rangeMessageFields(m, func(step fieldStep, nested protoreflect.Message) bool {
+		annotateMessage(nested, step.lookup(obj))
+		return true
+	})
+}
+
+// fieldStep is the step from a message to a message nested in one of its
+// fields.
+type fieldStep struct {
+	// name is the JSON name of the field, or the full name in brackets for
+	// extensions.
+	name string
+	// index is the index in a repeated field, or -1.
+	index int
+	// key is the key in a map field, if isKey is set.
+	key   string
+	isKey bool
+}
+
+func (s fieldStep) String() string {
+	switch {
+	case s.isKey:
+		return fmt.Sprintf("%s[%q]", s.name, s.key)
+	case s.index >= 0:
+		return fmt.Sprintf("%s[%d]", s.name, s.index)
+	default:
+		return s.name
+	}
+}
+
+// join appends the step to the path of the parent message.
+func (s fieldStep) join(path string) string {
+	if path == "" {
+		return s.String()
+	}
+	return path + "." + s.String()
+}
+
+// lookup returns the JSON value of the nested message in the JSON object of
+// the parent message, or nil if it doesn't exist.
+func (s fieldStep) lookup(obj *jsonObject) any {
+	v := obj.values[s.name]
+	switch {
+	case s.isKey:
+		m, ok := v.(*jsonObject)
+		if !ok {
+			return nil
+		}
+		return m.values[s.key]
+	case s.index >= 0:
+		l, _ := v.([]any)
+		if s.index >= len(l) {
+			return nil
+		}
+		return l[s.index]
+	default:
+		return v
+	}
+}
+
+// rangeMessageFields calls fn for all populated messages nested in m.
+func rangeMessageFields(m protoreflect.Message, fn func(step fieldStep, nested protoreflect.Message) bool) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		step := fieldStep{name: fd.JSONName(), index: -1}
+		if fd.IsExtension() {
+			step.name = "[" + string(fd.FullName()) + "]"
+		}
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			cont := true
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				step.key, step.isKey = k.String(), true
+				cont = fn(step, mv.Message())
+				return cont
+			})
+			return cont
+		case fd.IsList():
+			if fd.Message() == nil {
+				return true
+			}
+			for i := 0; i < v.List().Len(); i++ {
+				step.index = i
+				if !fn(step, v.List().Get(i).Message()) {
+					return false
+				}
+			}
+			return true
+		case fd.Message() != nil:
+			return fn(step, v.Message())
+		default:
+			return true
+		}
+	})
+}
+
+// describePath describes the location of a message given by its path.
+func describePath(path string) string {
+	if path == "" {
+		return "the message"
+	}
+	return path
+}
@@ -0,0 +1,33 @@
+package proto
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestAnnotateUnknownFields(t *testing.T) {
+	name, pkg := "a<b>&c", "z"
+	mes := &descriptorpb.FileDescriptorProto{
+		Name:    &name,
+		Package: &pkg,
+		Options: &descriptorpb.FileOptions{},
+	}
+	mes.Options.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 999, protowire.VarintType), 1))
+	b, err := protojson.Marshal(mes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := annotateUnknownFields(b, mes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantPrefix := `{"name":"a<b>&c","package":"z","options":{"@unknownFields":[`
+	if !strings.HasPrefix(string(got), wantPrefix) {
+		t.Fatalf("expected prefix %s, got %s", wantPrefix, got)
+	}
+}
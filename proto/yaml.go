@@ -0,0 +1,172 @@
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML input and output use the protojson mapping. Documents are converted
+// from and to JSON, so names, enums, 64-bit integers and well-known types
+// are represented the same way.
+
+// yamlToJSON converts a YAML document to JSON. An empty document is an empty
+// message.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	return yamlNodeToJSON(&node)
+}
+
+func yamlNodeToJSON(node *yaml.Node) ([]byte, error) {
+	v, err := jsonValueFromYAML(node)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(v)
+}
+
+// isEmptyYAMLDocument reports whether the document has no content. An
+// explicit null is not empty.
+func isEmptyYAMLDocument(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return true
+	}
+	content := node.Content[0]
+	return content.Kind == yaml.ScalarNode && content.ShortTag() == "!!null" && content.Value == ""
+}
+
+// jsonValueFromYAML converts a YAML node into a value that can be marshaled
+// as JSON. Strings, timestamps and binary data are kept as written, so they
+// are interpreted by protojson according to the field type.
+func jsonValueFromYAML(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return jsonValueFromYAML(node.Content[0])
+	case yaml.AliasNode:
+		return jsonValueFromYAML(node.Alias)
+	case yaml.MappingNode:
+		// Keys of map fields may be numbers or bools in YAML, but are always
+		// strings in JSON.
+		obj := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be scalars", key.Line)
+			}
+			converted, err := jsonValueFromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			obj[key.Value] = converted
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, value := range node.Content {
+			converted, err := jsonValueFromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!int":
+		// Integers are kept exact, also beyond the range of float64.
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		}
+		var u uint64
+		err := node.Decode(&u)
+		return json.Number(strconv.FormatUint(u, 10)), err
+	case "!!float":
+		var f float64
+		if err := node.Decode(&f); err != nil {
+			return nil, err
+		}
+		switch {
+		case math.IsNaN(f):
+			return "NaN", nil
+		case math.IsInf(f, 1):
+			return "Infinity", nil
+		case math.IsInf(f, -1):
+			return "-Infinity", nil
+		}
+		return f, nil
+	default:
+		return node.Value, nil
+	}
+}
+
+// jsonToYAML converts JSON produced by protojson into a YAML document. Keys
+// keep the order protojson wrote them in and numbers keep their exact
+// representation.
+func jsonToYAML(b []byte) ([]byte, error) {
+	v, err := decodeOrderedJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(v)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSuffix(sb.String(), "\n")), nil
+}
+
+func yamlNode(v any) *yaml.Node {
+	switch v := v.(type) {
+	case *jsonObject:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range v.keys {
+			node.Content = append(node.Content, yamlNode(key), yamlNode(v.values[key]))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, value := range v {
+			node.Content = append(node.Content, yamlNode(value))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
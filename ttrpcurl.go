@@ -1,96 +1,332 @@
 package ttrpcurl
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 
 	"github.com/containerd/ttrpc"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/katexochen/ttrpcurl/proto"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type Client struct {
-	ttrpc           ttrpcClient
-	source          *proto.Source
-	outputMarshaler proto.Marshaler
+	ttrpc            ttrpcClient
+	source           *proto.Source
+	inputUnmarshaler proto.Unmarshaler
+	outputMarshaler  proto.Marshaler
+	output           *proto.MessageWriter
+	strictSchema     bool
+	assignments      []proto.Assignment
+	delimitedOutput  bool
 }
 
-func NewClient(conn net.Conn, source *proto.Source, marsh proto.Marshaler) *Client {
-	return &Client{
-		ttrpc:           ttrpc.NewClient(conn),
-		source:          source,
-		outputMarshaler: marsh,
+// ClientOption configures optional behavior of a Client.
+type ClientOption func(*Client)
+
+// WithInputUnmarshaler sets the unmarshaler used to read requests. By
+// default, requests are read as JSON, resolving extensions and
+// google.protobuf.Any messages like the output marshaler.
+func WithInputUnmarshaler(unmarsh proto.Unmarshaler) ClientOption {
+	return func(c *Client) {
+		c.inputUnmarshaler = unmarsh
+	}
+}
+
+// WithStrictSchema makes calls fail if a response contains fields that are
+// unknown to the proto files. By default, a warning is printed.
+func WithStrictSchema() ClientOption {
+	return func(c *Client) {
+		c.strictSchema = true
+	}
+}
+
+// WithAssignments sets fields of the request after it has been unmarshaled
+// from the request data.
+func WithAssignments(assignments []proto.Assignment) ClientOption {
+	return func(c *Client) {
+		c.assignments = assignments
+	}
+}
+
+// WithDelimitedOutput prefixes responses in binary format with their size as
+// varint, so the responses of a streaming method can be separated. By
+// default, the response is written as is.
+func WithDelimitedOutput() ClientOption {
+	return func(c *Client) {
+		c.delimitedOutput = true
 	}
 }
 
+// NewClient creates a client that writes responses marshaled by marsh to
+// stdout. Requests are read as JSON, unless WithInputUnmarshaler is given.
+func NewClient(conn net.Conn, source *proto.Source, marsh proto.Marshaler, opts ...ClientOption) *Client {
+	c := &Client{
+		ttrpc:            ttrpc.NewClient(conn),
+		source:           source,
+		inputUnmarshaler: proto.Unmarshaler{Resolver: marsh.Resolver},
+		outputMarshaler:  marsh,
+		output:           proto.NewMessageWriter(os.Stdout, marsh),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
 func (c *Client) Call(ctx context.Context, method string, reqBytes []byte) error {
 	mth, err := c.source.FindMethod(method)
 	if err != nil {
 		return err
 	}
 
-	switch {
-	case mth.IsClientStreaming() && mth.IsServerStreaming():
-		return c.callBidirectionalSteaming(ctx, mth, reqBytes)
-	case mth.IsClientStreaming():
-		return c.callClientSteaming(ctx, mth, reqBytes)
-	case mth.IsServerStreaming():
-		return c.callServerSteaming(ctx, mth, reqBytes)
-	default:
-		return c.callUnary(ctx, mth, reqBytes)
+	if mth.IsClientStreaming() || mth.IsServerStreaming() {
+		return c.callStreaming(ctx, mth, reqBytes)
 	}
+	return c.callUnary(ctx, mth, reqBytes)
 }
 
 func (c *Client) callUnary(ctx context.Context, mth *desc.MethodDescriptor, reqBytes []byte) error {
-	req := dynamicpb.NewMessage(mth.GetInputType().UnwrapMessage())
+	req, err := c.readRequest(mth, reqBytes)
+	if err != nil {
+		return err
+	}
 	resp := dynamicpb.NewMessage(mth.GetOutputType().UnwrapMessage())
 
-	if len(reqBytes) != 0 {
-		if err := protojson.Unmarshal(reqBytes, req); err != nil {
+	serviceFQN := mth.GetService().GetFullyQualifiedName()
+	methodName := mth.GetName()
+
+	if err := c.ttrpc.Call(ctx, serviceFQN, methodName, req, resp); err != nil {
+		return err
+	}
+
+	return c.writeResponse(resp)
+}
+
+// callStreaming calls a method with a stream of requests, a stream of
+// responses or both. Requests are sent while responses are received, so
+// servers may respond to each request immediately.
+func (c *Client) callStreaming(ctx context.Context, mth *desc.MethodDescriptor, reqBytes []byte) error {
+	if mth.IsServerStreaming() && c.rawOutput() {
+		return fmt.Errorf("responses of streaming method %s can't be separated in binary format, use delimited output", mth.GetFullyQualifiedName())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamDesc := &ttrpc.StreamDesc{
+		StreamingClient: mth.IsClientStreaming(),
+		StreamingServer: mth.IsServerStreaming(),
+	}
+	// The single request of a server streaming method is sent when the stream
+	// is opened.
+	var req interface{}
+	if !streamDesc.StreamingClient {
+		r, err := c.readRequest(mth, reqBytes)
+		if err != nil {
 			return err
 		}
+		req = r
 	}
 
-	if !req.IsValid() {
-		return fmt.Errorf("marshaled input is invalid request")
+	serviceFQN := mth.GetService().GetFullyQualifiedName()
+	stream, err := c.ttrpc.NewStream(ctx, streamDesc, serviceFQN, mth.GetName(), req)
+	if err != nil {
+		return err
 	}
 
-	serviceFQN := mth.GetService().GetFullyQualifiedName()
-	methodName := mth.GetName()
+	sendErr := make(chan error, 1)
+	if streamDesc.StreamingClient {
+		go func() {
+			err := c.sendRequests(ctx, stream, mth, reqBytes)
+			if err != nil {
+				// Stop receiving, the server would wait for further requests.
+				cancel()
+			}
+			sendErr <- err
+		}()
+	} else {
+		sendErr <- nil
+	}
 
-	if err := c.ttrpc.Call(ctx, serviceFQN, methodName, req, resp); err != nil {
-		return err
+	for {
+		resp := dynamicpb.NewMessage(mth.GetOutputType().UnwrapMessage())
+		if err := stream.RecvMsg(resp); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// Stop sending, the server may have abandoned the stream.
+			cancel()
+			// If receiving was canceled because sending failed, the send
+			// error is the cause.
+			if sendErr := <-sendErr; sendErr != nil && errors.Is(err, context.Canceled) {
+				return sendErr
+			}
+			return err
+		}
+		if err := c.writeResponse(resp); err != nil {
+			return err
+		}
+		if !streamDesc.StreamingServer {
+			break
+		}
+	}
+	return <-sendErr
+}
+
+// sendRequests sends all requests in reqBytes and closes the sending side of
+// the stream, unless ctx is canceled. Without request data, no requests are
+// sent, unless there are assignments, which are applied to a single empty
+// request.
+func (c *Client) sendRequests(ctx context.Context, stream ttrpc.ClientStream, mth *desc.MethodDescriptor, reqBytes []byte) error {
+	reader := proto.NewMessageReader(bytes.NewReader(reqBytes), c.inputUnmarshaler)
+	for sent := 0; ; sent++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		req := dynamicpb.NewMessage(mth.GetInputType().UnwrapMessage())
+		if err := reader.Next(req); errors.Is(err, io.EOF) {
+			if sent > 0 || len(reqBytes) != 0 || len(c.assignments) == 0 {
+				break
+			}
+		} else if err != nil {
+			return err
+		}
+		if err := c.applyAssignments(req); err != nil {
+			return err
+		}
+		if err := stream.SendMsg(req); err != nil {
+			return fmt.Errorf("sending request: %w", err)
+		}
 	}
+	return stream.CloseSend()
+}
+
+// readRequest reads the single request of a method. Without request data,
+// an empty request is used.
+func (c *Client) readRequest(mth *desc.MethodDescriptor, reqBytes []byte) (*dynamicpb.Message, error) {
+	req := dynamicpb.NewMessage(mth.GetInputType().UnwrapMessage())
+	switch {
+	case c.inputUnmarshaler.Format == "binary":
+		// Binary requests are length-delimited, like the requests of
+		// streaming methods.
+		reader := proto.NewMessageReader(bytes.NewReader(reqBytes), c.inputUnmarshaler)
+		if err := reader.Next(req); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if err := reader.Next(dynamicpb.NewMessage(mth.GetInputType().UnwrapMessage())); !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("request data contains more than one message, but %s takes a single request", mth.GetFullyQualifiedName())
+		}
+	case len(reqBytes) != 0:
+		if err := c.inputUnmarshaler.Unmarshal(reqBytes, req); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyAssignments(req); err != nil {
+		return nil, err
+	}
+	if !req.IsValid() {
+		return nil, fmt.Errorf("marshaled input is invalid request")
+	}
+	return req, nil
+}
 
+func (c *Client) applyAssignments(req *dynamicpb.Message) error {
+	for _, a := range c.assignments {
+		if err := a.Apply(req, c.inputUnmarshaler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResponse checks the response and writes it to stdout.
+func (c *Client) writeResponse(resp *dynamicpb.Message) error {
 	if !resp.IsValid() {
 		return fmt.Errorf("received invalid response")
 	}
 
-	respBytes, err := c.outputMarshaler.Marshal(resp)
-	if err != nil {
+	// Extensions are resolved for all formats, otherwise they would be
+	// reported as unknown fields.
+	if err := proto.ResolveExtensions(resp, c.outputMarshaler.Resolver); err != nil {
+		return err
+	}
+	if err := c.checkUnknownFields(resp); err != nil {
 		return err
 	}
 
-	fmt.Fprintln(os.Stdout, string(respBytes))
-	return err
+	if c.rawOutput() {
+		b, err := c.outputMarshaler.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write(b); err != nil {
+			return fmt.Errorf("writing response: %w", err)
+		}
+		return nil
+	}
+	return c.output.Write(resp)
 }
 
-func (c *Client) callServerSteaming(_ context.Context, _ *desc.MethodDescriptor, _ []byte) error {
-	panic("server streaming not implemented")
+// rawOutput reports whether responses are written in binary format without
+// length prefix.
+func (c *Client) rawOutput() bool {
+	return c.outputMarshaler.Format == "binary" && !c.delimitedOutput
 }
 
-func (c *Client) callClientSteaming(_ context.Context, _ *desc.MethodDescriptor, _ []byte) error {
-	panic("client streaming not implemented")
+// checkUnknownFields reports fields of the response that are unknown to the
+// proto files, which indicates that the server uses newer proto files. They
+// are printed as warnings, unless strict schema checking is enabled.
+func (c *Client) checkUnknownFields(resp protoreflect.ProtoMessage) error {
+	unknown, err := proto.FindUnknownFields(resp.ProtoReflect())
+	if err != nil {
+		return fmt.Errorf("checking response for unknown fields: %w", err)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	level := "Warning"
+	if c.strictSchema {
+		level = "Error"
+	}
+	for _, field := range unknown {
+		location := "response"
+		if field.Path != "" {
+			location = "response field " + field.Path
+		}
+		fmt.Fprintf(os.Stderr, "%s: unknown field %d (%s) in %s\n", level, field.Number, field.WireType, location)
+	}
+	if c.strictSchema && len(unknown) == 1 {
+		return fmt.Errorf("response contains a field unknown to the proto files")
+	} else if c.strictSchema {
+		return fmt.Errorf("response contains %d fields unknown to the proto files", len(unknown))
+	}
+	return nil
 }
 
-func (c *Client) callBidirectionalSteaming(_ context.Context, _ *desc.MethodDescriptor, _ []byte) error {
-	panic("bidirectional streaming not implemented")
+// CallRaw calls the method of the service with the given request in wire
+// format and returns the response in wire format. No descriptors are needed.
+func (c *Client) CallRaw(ctx context.Context, service, method string, reqBytes []byte) ([]byte, error) {
+	// The ttrpc codec only accepts proto messages. The bytes are passed as
+	// unknown fields of an empty message, which are marshaled unchanged.
+	req := &emptypb.Empty{}
+	req.ProtoReflect().SetUnknown(reqBytes)
+	resp := &emptypb.Empty{}
+
+	if err := c.ttrpc.Call(ctx, service, method, req, resp); err != nil {
+		return nil, err
+	}
+	return resp.ProtoReflect().GetUnknown(), nil
 }
 
 type ttrpcClient interface {
 	Call(ctx context.Context, service, method string, req, resp interface{}) error
+	NewStream(ctx context.Context, desc *ttrpc.StreamDesc, service, method string, req interface{}) (ttrpc.ClientStream, error)
 }